@@ -1,6 +1,10 @@
 package sqlmock
 
-import "database/sql/driver"
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+)
 
 // Argument interface allows to match
 // any argument in specific way when used with
@@ -22,3 +26,163 @@ type anyArgument struct{}
 func (a anyArgument) Match(_ driver.Value) bool {
 	return true
 }
+
+// mismatchExplainer is implemented by arguments, which
+// can describe why a value does not match in detail
+type mismatchExplainer interface {
+	mismatch(driver.Value) error
+}
+
+// argumentMismatch returns an error describing why the matcher
+// could not match argument k, in detail if the matcher supports it
+func argumentMismatch(matcher Argument, k int, arg interface{}, v driver.Value) error {
+	if ex, ok := matcher.(mismatchExplainer); ok {
+		return fmt.Errorf("argument %d: %s", k, ex.mismatch(v))
+	}
+	return fmt.Errorf("matcher %T could not match %d argument %T - %+v", matcher, k, arg, arg)
+}
+
+// BytesArg will return an Argument which matches
+// a BLOB argument having exactly the expected bytes.
+// On mismatch, the error reports the first differing
+// offset and the bytes around it in hex.
+func BytesArg(expected []byte) Argument {
+	return bytesArgument(expected)
+}
+
+type bytesArgument []byte
+
+func (a bytesArgument) Match(v driver.Value) bool {
+	return a.mismatch(v) == nil
+}
+
+// bytesWindow is the number of bytes shown
+// on each side of the first differing offset
+const bytesWindow = 4
+
+func (a bytesArgument) mismatch(v driver.Value) error {
+	var actual []byte
+	switch b := v.(type) {
+	case []byte:
+		actual = b
+	case string:
+		actual = []byte(b)
+	default:
+		return fmt.Errorf("expected %d bytes, but got %T - %+v", len(a), v, v)
+	}
+
+	offset := 0
+	for offset < len(a) && offset < len(actual) && a[offset] == actual[offset] {
+		offset++
+	}
+	if offset == len(a) && offset == len(actual) {
+		return nil
+	}
+	return fmt.Errorf("expected %d bytes, but got %d bytes, which differ at offset %d: expected %s, but got %s",
+		len(a), len(actual), offset, hexWindow(a, offset), hexWindow(actual, offset))
+}
+
+// hexWindow formats bytes around offset in hex
+func hexWindow(b []byte, offset int) string {
+	from, to := offset-bytesWindow, offset+bytesWindow+1
+	if from < 0 {
+		from = 0
+	}
+	if to > len(b) {
+		to = len(b)
+	}
+
+	var prefix, suffix string
+	if from > 0 {
+		prefix = "... "
+	}
+	if to < len(b) {
+		suffix = " ..."
+	}
+	if from >= to {
+		return "[" + prefix + "<end>]"
+	}
+	return fmt.Sprintf("[%s% x%s]", prefix, b[from:to], suffix)
+}
+
+// UnorderedArgs will return a value, which passed as the only
+// argument to WithArgs, matches the actual arguments as a multiset,
+// regardless of their position. Useful for IN lists and bulk
+// statements built without a stable argument order. Values may
+// be Argument matchers. On mismatch, the error reports the
+// missing and the extra values.
+func UnorderedArgs(values ...driver.Value) driver.Value {
+	return unorderedArgs(values)
+}
+
+type unorderedArgs []driver.Value
+
+// unordered returns the expected unordered args, if
+// they were given to WithArgs as the only argument
+func unordered(args []driver.Value) (unorderedArgs, bool) {
+	if len(args) != 1 {
+		return nil, false
+	}
+	u, ok := args[0].(unorderedArgs)
+	return u, ok
+}
+
+func (u unorderedArgs) match(converter driver.ValueConverter, args []namedValue) error {
+	expected := make([]driver.Value, len(u))
+	for i, val := range u {
+		if _, ok := val.(Argument); ok {
+			expected[i] = val
+			continue
+		}
+		darg, err := converter.ConvertValue(val)
+		if err != nil {
+			return fmt.Errorf("could not convert unordered argument %T - %+v to driver value: %s", val, val, err)
+		}
+		expected[i] = darg
+	}
+
+	pending := make([]driver.Value, len(args))
+	for i, arg := range args {
+		pending[i] = arg.Value
+	}
+
+	// exact values are paired first, so that matchers
+	// do not take the values expected exactly
+	used := make([]bool, len(expected))
+	for _, exact := range []bool{true, false} {
+		var extra []driver.Value
+		for _, v := range pending {
+			if !pairArg(expected, used, v, exact) {
+				extra = append(extra, v)
+			}
+		}
+		pending = extra
+	}
+
+	var missing []driver.Value
+	for i, val := range expected {
+		if !used[i] {
+			missing = append(missing, val)
+		}
+	}
+	if len(missing) > 0 || len(pending) > 0 {
+		return fmt.Errorf("arguments do not match regardless of order, missing: %+v, extra: %+v", missing, pending)
+	}
+	return nil
+}
+
+// pairArg marks the first unused expected value, either exact or
+// a matcher, which matches v as used and reports whether it did
+func pairArg(expected []driver.Value, used []bool, v driver.Value, exact bool) bool {
+	for i, val := range expected {
+		matcher, isMatcher := val.(Argument)
+		if used[i] || isMatcher == exact {
+			continue
+		}
+		if (isMatcher && matcher.Match(v)) || (!isMatcher && reflect.DeepEqual(val, v)) {
+			used[i] = true
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package sqlmock
 
 import (
 	"database/sql/driver"
+	"strings"
 	"testing"
 	"time"
 )
@@ -56,3 +57,69 @@ func TestByteSliceArgument(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestBytesArgument(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	blob := []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b}
+	mock.ExpectExec("INSERT INTO files").WithArgs(BytesArg(blob)).WillReturnResult(NewResult(1, 1))
+
+	corrupt := append([]byte{}, blob...)
+	corrupt[6] = 0xff
+	_, err = db.Exec("INSERT INTO files(content) VALUES (?)", corrupt)
+	expected := "argument 0: expected 12 bytes, but got 12 bytes, which differ at offset 6: expected [... 02 03 04 05 06 07 08 09 0a ...], but got [... 02 03 04 05 ff 07 08 09 0a ...]"
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("expected error to contain '%s', but got: %v", expected, err)
+	}
+
+	if _, err = db.Exec("INSERT INTO files(content) VALUES (?)", blob); err != nil {
+		t.Errorf("error '%s' was not expected, while inserting a file", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestBytesArgumentLength(t *testing.T) {
+	t.Parallel()
+	err := bytesArgument("abc").mismatch([]byte("ab"))
+	expected := "expected 3 bytes, but got 2 bytes, which differ at offset 2: expected [61 62 63], but got [61 62]"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s', but got: %v", expected, err)
+	}
+	if BytesArg(nil).Match(123) {
+		t.Error("expected an integer not to match bytes")
+	}
+}
+
+func TestUnorderedArgs(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("DELETE FROM users").
+		WithArgs(UnorderedArgs(3, AnyArg(), 1)).
+		WillReturnResult(NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM users").
+		WithArgs(UnorderedArgs(1, 2, 2)).
+		WillReturnResult(NewResult(0, 3))
+
+	if _, err := db.Exec("DELETE FROM users WHERE id IN (?, ?, ?)", 1, 3, 1); err != nil {
+		t.Errorf("error '%s' was not expected, while deleting users", err)
+	}
+
+	_, err = db.Exec("DELETE FROM users WHERE id IN (?, ?, ?)", 2, 1, 4)
+	expected := "arguments do not match regardless of order, missing: [2], extra: [4]"
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("expected error containing '%s', but got: %v", expected, err)
+	}
+}
@@ -0,0 +1,205 @@
+package sqlmock
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// CallKind describes the kind of database call
+// recorded by sqlmock.
+type CallKind string
+
+// Kinds of recorded database calls
+const (
+	CallExec  CallKind = "Exec"
+	CallQuery CallKind = "Query"
+)
+
+// Call is a record of a database call, which was
+// matched by one of the sqlmock expectations.
+type Call struct {
+	Kind  CallKind
+	Query string
+	Args  []driver.Value
+
+	// Conn identifies the connection which was used
+	// for the call, connections are numbered in order
+	// they were opened, starting from 1.
+	Conn int
+
+	// InTx is true if the call was made within
+	// a transaction, false in auto-commit mode.
+	InTx bool
+
+	// Tx identifies the transaction the call was made
+	// within, transactions are numbered in order they
+	// were begun, starting from 1. It is 0 in auto-commit
+	// mode.
+	Tx int
+
+	// WithContext is true if the call was made with a context
+	// given by the caller, like by *sql.DB.QueryContext. Calls
+	// made without one, or with context.Background or context.TODO
+	// are indistinguishable, since database/sql defaults the context
+	// to context.Background.
+	WithContext bool
+
+	// Hints holds the contents of optimizer hint
+	// comments like "/*+ INDEX(users idx_name) */"
+	// found in the query.
+	Hints []string
+
+	// Fingerprint is the normalized query, with whitespace
+	// collapsed and literal values replaced by "?".
+	Fingerprint string
+
+	// Sensitive holds ordinals of arguments, starting from 1,
+	// which were marked as sensitive by the matched expectation
+	// with WithSensitiveArgs.
+	Sensitive []int
+
+	ex expectation // matched expectation
+}
+
+// record adds a call to the list of matched calls
+func (c *sqlmock) record(call *Call) {
+	c.mu.Lock()
+	c.calls = append(c.calls, *call)
+	c.mu.Unlock()
+}
+
+// matched calls the hook registered by OnMatchOption with the
+// call matched by an expectation, if any, once the expectation
+// was unlocked
+func (c *sqlmock) matched(call *Call) {
+	if call != nil && c.onMatch != nil {
+		c.onMatch(*call)
+	}
+}
+
+// RedactedArgs returns arguments of the call, with values of
+// sensitive arguments replaced by the given mask, as a logger
+// which redacts them is expected to see them.
+func (c Call) RedactedArgs(mask driver.Value) []driver.Value {
+	args := make([]driver.Value, len(c.Args))
+	copy(args, c.Args)
+	for _, ordinal := range c.Sensitive {
+		if ordinal > 0 && ordinal <= len(args) {
+			args[ordinal-1] = mask
+		}
+	}
+	return args
+}
+
+// Calls returns all calls matched so far, in the order
+// they were made.
+func (c *sqlmock) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	calls := make([]Call, len(c.calls))
+	copy(calls, c.calls)
+	return calls
+}
+
+// CallCount returns the number of calls
+// of the given kind matched so far
+func (c *sqlmock) CallCount(kind CallKind) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var count int
+	for _, call := range c.calls {
+		if call.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// AssertCallCountAtMost checks that at most n calls
+// of the given kind were matched so far
+func (c *sqlmock) AssertCallCountAtMost(kind CallKind, n int) error {
+	if count := c.CallCount(kind); count > n {
+		return fmt.Errorf("expected at most %d %s calls, but got %d", n, kind, count)
+	}
+	return nil
+}
+
+// AssertCalledOnce checks that exactly one call matched
+// so far has SQL matching pattern
+func (c *sqlmock) AssertCalledOnce(pattern string) error {
+	var count int
+	for _, call := range c.Calls() {
+		if c.queryMatcher.Match(pattern, call.Query) == nil {
+			count++
+		}
+	}
+	if count != 1 {
+		return fmt.Errorf("expected exactly one call matching '%s', but got %d", pattern, count)
+	}
+	return nil
+}
+
+// DistinctQueries returns the fingerprints of distinct SQL statements
+// of all calls matched so far, in the order they were first made.
+// Statements are considered the same when they have the same
+// Fingerprint, that is when they differ only by whitespace or literal
+// values, regardless of arguments or how many times they ran.
+func (c *sqlmock) DistinctQueries() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var queries []string
+	seen := make(map[string]bool)
+	for _, call := range c.calls {
+		if !seen[call.Fingerprint] {
+			seen[call.Fingerprint] = true
+			queries = append(queries, call.Fingerprint)
+		}
+	}
+	return queries
+}
+
+// DistinctQueryCount returns the number of distinct
+// SQL statements of all calls matched so far.
+func (c *sqlmock) DistinctQueryCount() int {
+	return len(c.DistinctQueries())
+}
+
+// AssertStableFingerprints checks that calls matched by
+// the same expectation had the same fingerprint
+func (c *sqlmock) AssertStableFingerprints() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	first := make(map[expectation]string)
+	for _, call := range c.calls {
+		fp, ok := first[call.ex]
+		if !ok {
+			first[call.ex] = call.Fingerprint
+			continue
+		}
+		if fp != call.Fingerprint {
+			return fmt.Errorf("expected calls to have a stable fingerprint '%s', but got '%s' for: %s", fp, call.Fingerprint, call.ex)
+		}
+	}
+	return nil
+}
+
+// bind adds argument values of an exec or query
+// to the list of all bound arguments
+func (c *sqlmock) bind(args []namedValue) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	c.mu.Lock()
+	c.bound = append(c.bound, values)
+	c.mu.Unlock()
+}
+
+// AllBoundArgs returns argument values bound so far
+func (c *sqlmock) AllBoundArgs() [][]driver.Value {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	bound := make([][]driver.Value, len(c.bound))
+	copy(bound, c.bound)
+	return bound
+}
@@ -0,0 +1,35 @@
+package sqlmock
+
+// Column is a mocked column definition
+// for rows returned by a query
+type Column struct {
+	name       string
+	length     int64
+	definesLen bool
+}
+
+// NewColumn returns a Column with the given name,
+// which may be used to define rows columns in
+// NewRowsWithColumnDefinition
+func NewColumn(name string) *Column {
+	return &Column{name: name}
+}
+
+// WithLength sets a variable length of the column, like the
+// length of a VARCHAR column, which is reported through
+// sql.ColumnType.Length
+func (c *Column) WithLength(length int64) *Column {
+	c.length = length
+	c.definesLen = true
+	return c
+}
+
+// Name returns the column name
+func (c *Column) Name() string {
+	return c.name
+}
+
+// Length returns the column length and whether it was defined
+func (c *Column) Length() (int64, bool) {
+	return c.length, c.definesLen
+}
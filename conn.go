@@ -0,0 +1,268 @@
+package sqlmock
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// conn is a single database connection opened
+// on a mock database. All connections opened with
+// the same dsn share expectations of their sqlmock,
+// but keep track of their own session state.
+// meets http://golang.org/pkg/database/sql/driver/#Conn interface
+type conn struct {
+	*sqlmock
+	id       int
+	tx       int // number of the current transaction
+	inTx     bool
+	readOnly bool
+	aborted  bool
+
+	skipped     string // query which fast path was skipped with driver.ErrSkip
+	withContext bool   // the current call was given a context
+	resetFailed bool   // session reset failed, discarding the connection
+	openStmts   int    // statements prepared on the connection and not closed
+	lost        bool   // returned driver.ErrBadConn while statements were open
+
+	sessionTimeout  time.Duration // statement timeout set for the session
+	localTimeout    time.Duration // statement timeout set for the transaction
+	localTimeoutSet bool
+
+	handshake []expectation   // expectations set up by OnConnect
+	closing   <-chan struct{} // closed once the connector is closed by sql.DB.Close
+}
+
+// call creates a record of the call made on this connection
+func (c *conn) call(kind CallKind, query string, args []namedValue) *Call {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return &Call{
+		Kind:  kind,
+		Query: query,
+		Args:  values,
+		Conn:  c.id,
+		InTx:  c.inTx,
+		Tx:    c.tx,
+		Hints: queryHints(query),
+
+		WithContext: c.withContext,
+
+		Fingerprint: fingerprint(query),
+	}
+}
+
+// beginTx sets transaction state of the connection
+// and records the transaction on the matched begin
+func (c *conn) beginTx(ex *ExpectedBegin, readOnly bool) {
+	c.mu.Lock()
+	c.txs++
+	c.tx = c.txs
+	c.mu.Unlock()
+	ex.Lock()
+	ex.tx = c.tx
+	ex.Unlock()
+	c.inTx, c.readOnly = true, readOnly
+	c.txEvent(TxBegin, c.tx, nil)
+}
+
+// endTx resets transaction state of the connection
+// and reports whether the transaction was aborted
+func (c *conn) endTx() (aborted bool) {
+	c.endSavepoints()
+	c.localTimeoutSet = false
+	aborted = c.aborted
+	c.inTx, c.readOnly, c.aborted, c.tx = false, false, false, 0
+	return aborted
+}
+
+// writeAllowed checks whether query may be executed
+// in the current transaction, when it is read-only
+func (c *conn) writeAllowed(query string) error {
+	if !c.inTx || !c.readOnly {
+		return nil
+	}
+	switch kw := statementKeyword(query); kw {
+	case "INSERT", "UPDATE", "DELETE", "MERGE", "REPLACE", "UPSERT":
+		return fmt.Errorf("cannot execute %s in a read-only transaction", kw)
+	}
+	return nil
+}
+
+// MaxConcurrentConnections returns the peak number
+// of simultaneously open connections
+func (c *sqlmock) MaxConcurrentConnections() int {
+	c.drv.Lock()
+	defer c.drv.Unlock()
+	return c.peakOpened
+}
+
+// ConnectionReuseCount returns the number of times
+// an idle connection was reused by the pool
+func (c *sqlmock) ConnectionReuseCount() int {
+	c.drv.Lock()
+	defer c.drv.Unlock()
+	return c.reused
+}
+
+// PoisonConnection marks all connections opened so far as bad
+func (c *sqlmock) PoisonConnection() {
+	c.drv.Lock()
+	defer c.drv.Unlock()
+	c.poisoned = c.connections
+}
+
+// bad reports whether the connection was poisoned
+func (c *conn) bad() bool {
+	c.drv.Lock()
+	defer c.drv.Unlock()
+	return c.id <= c.poisoned
+}
+
+// lostConn reports whether the connection was poisoned, or returned
+// driver.ErrBadConn while statements were still open on it. A connection
+// closed by the pool, like when it exceeds the idle limit, is not lost.
+func (c *conn) lostConn() bool {
+	c.drv.Lock()
+	defer c.drv.Unlock()
+	return c.id <= c.poisoned || c.lost
+}
+
+// badConnReturned marks the connection as lost, if err is
+// driver.ErrBadConn, while statements are still open on it
+func (c *conn) badConnReturned(err error) {
+	if err != driver.ErrBadConn {
+		return
+	}
+	c.drv.Lock()
+	c.lost = c.lost || c.openStmts > 0
+	c.drv.Unlock()
+}
+
+// newStatement opens a statement prepared on the connection
+func (c *conn) newStatement(ex *ExpectedPrepare, query string) *statement {
+	c.drv.Lock()
+	c.openStmts++
+	c.drv.Unlock()
+	stmt := &statement{conn: c, ex: ex, query: query}
+	ex.Lock()
+	ex.stmts = append(ex.stmts, stmt)
+	ex.Unlock()
+	return stmt
+}
+
+// rePrepared looks for an already triggered prepare expectation of
+// query, which statements were all lost along with connections they
+// were prepared on, since database/sql prepares such statement again
+// on a new connection. It is looked for only when no pending prepare
+// expectation matches query.
+func (c *conn) rePrepared(query string) *ExpectedPrepare {
+	for _, next := range c.expected {
+		pr, ok := next.(*ExpectedPrepare)
+		if !ok {
+			continue
+		}
+		pr.Lock()
+		conns := pr.conns
+		pr.Unlock()
+		if len(conns) == 0 || c.queryMatcher.Match(pr.expectSQL, query) != nil {
+			continue
+		}
+
+		lost := true
+		for _, prev := range conns {
+			lost = lost && prev != c && prev.lostConn()
+		}
+		if !lost {
+			continue
+		}
+
+		pr.Lock()
+		pr.conns = append(pr.conns, c)
+		pr.Unlock()
+
+		c.mu.Lock()
+		if c.rePrepares == nil {
+			c.rePrepares = make(map[string]int)
+		}
+		c.rePrepares[stripQuery(query)]++
+		c.mu.Unlock()
+		c.prepared(query, true)
+		return pr
+	}
+	return nil
+}
+
+// skippedPrepare prepares a statement for the query, which
+// fast path was skipped, since database/sql falls back to
+// preparing it on the same connection
+func (c *conn) skippedPrepare(query string) *ExpectedPrepare {
+	if skipped := c.takeSkipped(); skipped == "" || skipped != query {
+		return nil
+	}
+
+	ex := &ExpectedPrepare{expectSQL: query, mock: c.sqlmock, conns: []*conn{c}, fallback: true}
+	ex.triggered = true
+	c.txStatement(query, func(s *TxStatementStats) { s.Prepares++ })
+	c.prepared(query, false)
+	return ex
+}
+
+// takeSkipped returns the query which fast path was skipped by the
+// previous call on the connection and forgets it, since database/sql
+// falls back to prepare it right away, with the next call
+func (c *conn) takeSkipped() string {
+	skipped := c.skipped
+	c.skipped = ""
+	return skipped
+}
+
+// RePrepareCount returns how many times the statement
+// was prepared again after its connection was lost
+func (c *sqlmock) RePrepareCount(stmtSQL string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rePrepares[stripQuery(stmtSQL)]
+}
+
+// PrepareRecord describes a statement prepared at the driver level.
+type PrepareRecord struct {
+	Conn  int // connection the statement was prepared on
+	Query string
+
+	// RePrepared is true if the statement was prepared again
+	// after the connection it was prepared on was lost.
+	RePrepared bool
+}
+
+// prepared adds a prepared statement to the prepare history
+func (c *conn) prepared(query string, rePrepared bool) {
+	c.mu.Lock()
+	c.prepares = append(c.prepares, PrepareRecord{Conn: c.id, Query: query, RePrepared: rePrepared})
+	c.mu.Unlock()
+
+	c.stmtStats(query, func(s *StmtStats) {
+		s.Prepares++
+		if rePrepared {
+			s.RePrepares++
+		}
+	})
+}
+
+// PrepareHistory returns all statements prepared so far,
+// in the order they were prepared
+func (c *sqlmock) PrepareHistory() []PrepareRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prepares := make([]PrepareRecord, len(c.prepares))
+	copy(prepares, c.prepares)
+	return prepares
+}
+
+// IsValid meets https://golang.org/pkg/database/sql/driver/#Validator
+// a poisoned connection is not returned to the connection pool
+func (c *conn) IsValid() bool {
+	return !c.bad()
+}
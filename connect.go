@@ -0,0 +1,120 @@
+package sqlmock
+
+import "fmt"
+
+// ConnScope is used to set up expectations of a single
+// connection, given to the func registered with OnConnect.
+type ConnScope interface {
+	// Conn returns the number of the connection, connections
+	// are numbered in order they were opened, starting from 1.
+	Conn() int
+
+	// ExpectExec expects an exec to be run on the connection,
+	// before any other call is made on it. Execs and queries
+	// made before are matched against handshake expectations
+	// only, while any other call fails.
+	ExpectExec(expectedSQL string) *ExpectedExec
+
+	// ExpectQuery expects a query to be run on the connection,
+	// before any other call is made on it, like ExpectExec.
+	ExpectQuery(expectedSQL string) *ExpectedQuery
+}
+
+type connScope struct {
+	conn *conn
+}
+
+func (s *connScope) Conn() int {
+	return s.conn.id
+}
+
+func (s *connScope) ExpectExec(expectedSQL string) *ExpectedExec {
+	e := &ExpectedExec{}
+	e.expectSQL = expectedSQL
+	e.converter = s.conn.converter
+	s.conn.handshake = append(s.conn.handshake, e)
+	return e
+}
+
+func (s *connScope) ExpectQuery(expectedSQL string) *ExpectedQuery {
+	e := &ExpectedQuery{}
+	e.expectSQL = expectedSQL
+	e.converter = s.conn.converter
+	s.conn.handshake = append(s.conn.handshake, e)
+	return e
+}
+
+// OnConnect registers fn to set up handshake expectations
+// of every connection opened from now on
+func (c *sqlmock) OnConnect(fn func(scope ConnScope)) {
+	c.mu.Lock()
+	c.onConnect = fn
+	c.mu.Unlock()
+}
+
+// connected sets up expectations of the newly opened connection
+func (c *conn) connected() {
+	c.mu.Lock()
+	fn := c.onConnect
+	c.mu.Unlock()
+	if fn == nil {
+		return
+	}
+
+	fn(&connScope{conn: c})
+	if len(c.handshake) > 0 {
+		c.mu.Lock()
+		c.handshakes = append(c.handshakes, c)
+		c.mu.Unlock()
+	}
+}
+
+// expectations returns the expectations calls on the connection
+// are matched against: its handshake ones, until all of them were
+// met, and then the ones of the mock
+func (c *conn) expectations() []expectation {
+	if c.pendingHandshake() != nil {
+		return c.handshake
+	}
+	return c.expected
+}
+
+// pendingHandshake returns the first handshake
+// expectation of the connection, which was not met
+func (c *conn) pendingHandshake() expectation {
+	for _, e := range c.handshake {
+		e.Lock()
+		met := e.fulfilled()
+		e.Unlock()
+		if !met {
+			return e
+		}
+	}
+	return nil
+}
+
+// beforeHandshake returns an error for the named call, which is
+// neither an exec nor a query, if it was made on the connection
+// before all its handshake expectations were met
+func (c *conn) beforeHandshake(call string) error {
+	if e := c.pendingHandshake(); e != nil {
+		return fmt.Errorf("call to %s, was not expected before the handshake of connection %d, next expectation is: %s", call, c.id, e)
+	}
+	return nil
+}
+
+// handshakesWereMet checks whether all connections
+// met expectations set up by OnConnect
+func (c *sqlmock) handshakesWereMet() error {
+	c.mu.Lock()
+	conns := make([]*conn, len(c.handshakes))
+	copy(conns, c.handshakes)
+	c.mu.Unlock()
+
+	for _, cn := range conns {
+		if e := cn.pendingHandshake(); e != nil {
+			return fmt.Errorf("connection %d did not complete its handshake, there is a remaining expectation which was not matched: %s", cn.id, e)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// +build go1.10
+
+package sqlmock
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+// handshakeConnector runs the handshake on every connection it opens
+type handshakeConnector struct {
+	dsn       string
+	handshake string
+}
+
+func (c handshakeConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	dc, err := pool.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	if c.handshake != "" {
+		if _, err = dc.(driver.ExecerContext).ExecContext(ctx, c.handshake, nil); err != nil {
+			dc.Close()
+			return nil, err
+		}
+	}
+	return dc, nil
+}
+
+func (c handshakeConnector) Driver() driver.Driver {
+	return pool
+}
+
+func TestOnConnectHandshake(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	dsn := mock.(*sqlmock).dsn
+
+	var scopes []int
+	mock.OnConnect(func(scope ConnScope) {
+		scopes = append(scopes, scope.Conn())
+		scope.ExpectExec("SET search_path").WillReturnResult(NewResult(0, 0))
+	})
+	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(NewRows([]string{"id"}).AddRow(1))
+
+	app := sql.OpenDB(handshakeConnector{dsn: dsn, handshake: "SET search_path TO app"})
+	var id int
+	if err = app.QueryRow("SELECT id FROM users").Scan(&id); err != nil {
+		t.Fatalf("error '%s' was not expected, while selecting users", err)
+	}
+	app.Close()
+
+	calls := mock.Calls()
+	if len(calls) != 2 || calls[0].Query != "SET search_path TO app" || calls[0].Conn != calls[1].Conn {
+		t.Errorf("expected the handshake to run on the connection before the query, but got %+v", calls)
+	}
+	if len(scopes) != 1 || scopes[0] != calls[0].Conn {
+		t.Errorf("expected expectations to be set up for connection %d, but got %v", calls[0].Conn, scopes)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(NewRows([]string{"id"}).AddRow(1))
+	skipping := sql.OpenDB(handshakeConnector{dsn: dsn})
+	defer skipping.Close()
+	err = skipping.QueryRow("SELECT id FROM users").Scan(&id)
+	if err == nil || !strings.Contains(err.Error(), "next expectation is: ExpectedExec => expecting Exec or ExecContext which:\n  - matches sql: 'SET search_path'") {
+		t.Errorf("expected the query to require the handshake first, but got: %v", err)
+	}
+	mock.ExpectBegin()
+	if _, err = skipping.Begin(); err == nil || !strings.Contains(err.Error(), "call to database transaction Begin, was not expected before the handshake of connection 3") {
+		t.Errorf("expected begin to require the handshake first, but got: %v", err)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err == nil || !strings.HasPrefix(err.Error(), "connection 3 did not complete its handshake") {
+		t.Errorf("expected the handshake of connection 3 to be reported, but got: %v", err)
+	}
+}
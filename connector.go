@@ -0,0 +1,49 @@
+// +build go1.10
+
+package sqlmock
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+)
+
+// connector opens connections of a mock database for a single
+// sql.DB. It is closed by sql.DB.Close since go1.17, which makes
+// calls made with a context on its connections, still waiting for
+// their delay, and reads of rows they returned, still waiting for
+// a row delay, return a cancellation error, as if the database
+// closed the connections.
+type connector struct {
+	dsn     string
+	drv     *mockDriver
+	closing chan struct{}
+	once    sync.Once
+}
+
+// OpenConnector implements driver.DriverContext interface
+func (d *mockDriver) OpenConnector(dsn string) (driver.Connector, error) {
+	return &connector{dsn: dsn, drv: d, closing: make(chan struct{})}, nil
+}
+
+// Connect implements driver.Connector interface
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	cn, err := c.drv.open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	cn.closing = c.closing
+	cn.connected()
+	return cn.driverConn(), nil
+}
+
+// Driver implements driver.Connector interface
+func (c *connector) Driver() driver.Driver {
+	return c.drv
+}
+
+// Close implements io.Closer interface
+func (c *connector) Close() error {
+	c.once.Do(func() { close(c.closing) })
+	return nil
+}
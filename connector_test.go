@@ -0,0 +1,135 @@
+// +build go1.17
+
+package sqlmock
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+)
+
+func TestCloseCancelsDelayedQuery(t *testing.T) {
+	t.Parallel()
+	matched := make(chan struct{})
+	db, mock, err := New(OnMatchOption(func(call Call) {
+		close(matched)
+	}))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	mock.ExpectQuery("SELECT pg_sleep").WillReturnRows(NewRows([]string{"slept"}).AddRow(true)).WillDelayFor(time.Second)
+
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		_, err := db.QueryContext(context.Background(), "SELECT pg_sleep(1)")
+		done <- err
+	}()
+
+	// once matched, the query reached the driver and is delayed
+	<-matched
+	if err := db.Close(); err != nil {
+		t.Fatalf("error '%s' was not expected, while closing the database", err)
+	}
+
+	if err := <-done; err != ErrCancelled {
+		t.Errorf("expected the query to be cancelled by Close, but got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("expected Close to interrupt the delay, but the query took %s", elapsed)
+	}
+}
+
+func TestCloseCancelsDelayedRow(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	rows := NewRows([]string{"id"}).AddRow(1).FirstRowDelay(time.Second)
+	mock.ExpectQuery("SELECT id FROM events").WillReturnRows(rows)
+
+	start := time.Now()
+	rs, err := db.QueryContext(context.Background(), "SELECT id FROM events")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while selecting events", err)
+	}
+	defer rs.Close()
+	if err := db.Close(); err != nil {
+		t.Fatalf("error '%s' was not expected, while closing the database", err)
+	}
+
+	if rs.Next() {
+		t.Error("expected no row to be read once the database was closed")
+	}
+	if err := rs.Err(); err != ErrCancelled {
+		t.Errorf("expected the row read to be cancelled by Close, but got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("expected Close to interrupt the row delay, but reading took %s", elapsed)
+	}
+}
+
+func TestCloseCancelsDelayedShardQuery(t *testing.T) {
+	t.Parallel()
+	db, shards, err := NewSharded(2, func(string, []driver.NamedValue) (int, error) { return 1, nil })
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	rows := NewRows([]string{"id"}).AddRow(1).FirstRowDelay(time.Second)
+	shards[1].ExpectQuery("SELECT id FROM events").WillReturnRows(rows)
+
+	rs, err := db.QueryContext(context.Background(), "SELECT id FROM events")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while selecting events", err)
+	}
+	defer rs.Close()
+	start := time.Now()
+	if err := db.Close(); err != nil {
+		t.Fatalf("error '%s' was not expected, while closing the database", err)
+	}
+
+	if rs.Next() {
+		t.Error("expected no row to be read once the database was closed")
+	}
+	if err := rs.Err(); err != ErrCancelled {
+		t.Errorf("expected the row read to be cancelled by Close, but got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("expected Close to interrupt the row delay, but reading took %s", elapsed)
+	}
+}
+
+func TestCloseCancelsNotificationWait(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	mock.ExpectExec("LISTEN orders").WillReturnResult(NewResult(0, 0))
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while opening a connection", err)
+	}
+	defer conn.Close()
+	if _, err = conn.ExecContext(ctx, "LISTEN orders"); err != nil {
+		t.Fatalf("error '%s' was not expected, while listening", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("error '%s' was not expected, while closing the database", err)
+	}
+
+	err = conn.Raw(func(dc interface{}) error {
+		_, err := dc.(NotificationWaiter).WaitForNotification(ctx)
+		return err
+	})
+	if err != ErrCancelled {
+		t.Errorf("expected the wait to be cancelled by Close, but got: %v", err)
+	}
+}
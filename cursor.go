@@ -0,0 +1,171 @@
+package sqlmock
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	declareCursor = regexp.MustCompile(`(?is)^\s*DECLARE\s+("[^"]+"|[A-Za-z_]\w*)\s+(?:[A-Z]+\s+)*?CURSOR\s+(?:(?:WITH|WITHOUT)\s+HOLD\s+)?FOR\s+(.*?)\s*;?\s*$`)
+	fetchCursor   = regexp.MustCompile(`(?is)^\s*FETCH\s+(?:(NEXT|ALL|FORWARD\s+ALL|FORWARD\s+\d+|FORWARD|\d+)\s+)?(?:(?:FROM|IN)\s+)?("[^"]+"|[A-Za-z_]\w*)\s*;?\s*$`)
+	closeCursor   = regexp.MustCompile(`(?is)^\s*CLOSE\s+("[^"]+"|[A-Za-z_]\w*)\s*;?\s*$`)
+)
+
+// ExpectedCursor is used to manage a server side cursor, which
+// is declared by a "DECLARE name CURSOR FOR query" exec, read by
+// "FETCH count FROM name" queries and closed by a "CLOSE name"
+// exec. Returned by *Sqlmock.ExpectCursor.
+//
+// Cursor statements are matched regardless of other expectations
+// and their order, since cursors are usually read while other
+// statements are executed. They are not recorded as calls, so
+// they are not reported by Calls, AllBoundArgs or OnMatchOption.
+type ExpectedCursor struct {
+	mock      *sqlmock // owning mock, which lock guards the cursor state
+	name      string
+	rows      *Rows
+	expectSQL string
+	declared  bool
+	open      bool
+	pos       int
+	fetches   int
+}
+
+// WithQuery expects the cursor to be declared for a query,
+// which matches the given SQL, using the mock query matcher.
+func (e *ExpectedCursor) WithQuery(expectedSQL string) *ExpectedCursor {
+	e.expectSQL = expectedSQL
+	return e
+}
+
+// Fetches returns the number of FETCH queries
+// which were made on the cursor.
+func (e *ExpectedCursor) Fetches() int {
+	e.mock.mu.Lock()
+	defer e.mock.mu.Unlock()
+	return e.fetches
+}
+
+// String returns string representation
+func (e *ExpectedCursor) String() string {
+	msg := fmt.Sprintf("ExpectedCursor => expecting cursor '%s' to be declared, fetched and closed", e.name)
+	if e.expectSQL != "" {
+		msg += fmt.Sprintf(", for query matching sql: '%s'", e.expectSQL)
+	}
+	return msg
+}
+
+func (c *sqlmock) ExpectCursor(name string, rows *Rows) *ExpectedCursor {
+	e := &ExpectedCursor{mock: c, name: name, rows: rows}
+	c.mu.Lock()
+	c.cursors = append(c.cursors, e)
+	c.mu.Unlock()
+	return e
+}
+
+// cursor returns the expected cursor by name, must
+// be called while holding the mock lock
+func (c *sqlmock) cursor(name string) *ExpectedCursor {
+	name = strings.Trim(name, `"`)
+	for _, e := range c.cursors {
+		if e.name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// cursorExec handles DECLARE and CLOSE commands of expected
+// cursors and reports whether query was handled
+func (c *sqlmock) cursorExec(query string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if m := declareCursor.FindStringSubmatch(query); m != nil {
+		e := c.cursor(m[1])
+		if e == nil {
+			return false, nil
+		}
+		if e.open {
+			return true, fmt.Errorf("cursor \"%s\" already exists", e.name)
+		}
+		if e.expectSQL != "" {
+			if err := c.queryMatcher.Match(e.expectSQL, m[2]); err != nil {
+				return true, fmt.Errorf("cursor \"%s\": %v", e.name, err)
+			}
+		}
+		e.declared, e.open, e.pos = true, true, 0
+		return true, nil
+	}
+
+	if m := closeCursor.FindStringSubmatch(query); m != nil {
+		e := c.cursor(m[1])
+		if e == nil {
+			return false, nil
+		}
+		if !e.open {
+			return true, fmt.Errorf("cursor \"%s\" does not exist", e.name)
+		}
+		e.open = false
+		return true, nil
+	}
+	return false, nil
+}
+
+// cursorFetch serves the next batch of rows of an expected
+// cursor for a FETCH query and reports whether it was handled
+func (c *sqlmock) cursorFetch(query string) (*Rows, bool, error) {
+	m := fetchCursor.FindStringSubmatch(query)
+	if m == nil {
+		return nil, false, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.cursor(m[2])
+	if e == nil {
+		return nil, false, nil
+	}
+	if !e.open {
+		return nil, true, fmt.Errorf("cursor \"%s\" does not exist", e.name)
+	}
+
+	remaining := len(e.rows.rows) - e.pos
+	count := 1
+	switch fields := strings.Fields(strings.ToUpper(m[1])); {
+	case len(fields) == 0 || fields[len(fields)-1] == "NEXT" || fields[len(fields)-1] == "FORWARD":
+	case fields[len(fields)-1] == "ALL":
+		count = remaining
+	default:
+		count, _ = strconv.Atoi(fields[len(fields)-1])
+	}
+	if count > remaining {
+		count = remaining
+	}
+
+	idx := make([]int, count)
+	for i := range idx {
+		idx[i] = e.pos + i
+	}
+	e.pos += count
+	e.fetches++
+	return e.rows.pick(idx), true, nil
+}
+
+// cursorsWereMet checks whether all expected
+// cursors were declared and closed
+func (c *sqlmock) cursorsWereMet() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range c.cursors {
+		if !e.declared {
+			return fmt.Errorf("there is a remaining expectation which was not matched: %s", e)
+		}
+		if e.open {
+			return fmt.Errorf("expected cursor to be closed, but it was not: %s", e)
+		}
+	}
+	return nil
+}
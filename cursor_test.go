@@ -0,0 +1,105 @@
+package sqlmock
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCursorFetchBatches(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := NewRows([]string{"id"})
+	for i := 1; i <= 5; i++ {
+		rows.AddRow(i)
+	}
+	mock.ExpectBegin()
+	cursor := mock.ExpectCursor("users_cur", rows).WithQuery("SELECT id FROM users")
+	mock.ExpectExec("UPDATE stats").WillReturnResult(NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while beginning a transaction", err)
+	}
+	if _, err = tx.Exec("DECLARE users_cur NO SCROLL CURSOR FOR SELECT id FROM users ORDER BY id"); err != nil {
+		t.Fatalf("error '%s' was not expected, while declaring a cursor", err)
+	}
+
+	var batches []string
+	for {
+		rs, err := tx.Query("FETCH 2 FROM users_cur")
+		if err != nil {
+			t.Fatalf("error '%s' was not expected, while fetching from a cursor", err)
+		}
+		var ids []int
+		for rs.Next() {
+			var id int
+			if err := rs.Scan(&id); err != nil {
+				t.Fatalf("error '%s' was not expected, while scanning a row", err)
+			}
+			ids = append(ids, id)
+		}
+		rs.Close()
+		if len(ids) == 0 {
+			break
+		}
+		batches = append(batches, fmt.Sprint(ids))
+	}
+
+	if _, err = tx.Exec("UPDATE stats SET reads = reads + 1"); err != nil {
+		t.Fatalf("error '%s' was not expected, while updating stats", err)
+	}
+	if _, err = tx.Exec("CLOSE users_cur"); err != nil {
+		t.Fatalf("error '%s' was not expected, while closing a cursor", err)
+	}
+	if _, err = tx.Query("FETCH NEXT FROM users_cur"); err == nil {
+		t.Error("expected an error, while fetching from a closed cursor")
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("error '%s' was not expected, while committing a transaction", err)
+	}
+
+	if actual := fmt.Sprint(batches); actual != "[[1 2] [3 4] [5]]" {
+		t.Errorf("expected rows to be fetched in batches of 2, but got %s", actual)
+	}
+	if cursor.Fetches() != 4 {
+		t.Errorf("expected 4 fetches, but got %d", cursor.Fetches())
+	}
+	if calls := mock.Calls(); len(calls) != 1 || calls[0].Query != "UPDATE stats SET reads = reads + 1" {
+		t.Errorf("expected cursor statements not to be recorded as calls, but got %+v", calls)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCursorMustBeClosed(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectCursor("cur", NewRows([]string{"id"}).AddRow(1))
+
+	if err := mock.ExpectationsWereMet(); err == nil {
+		t.Error("expected an error, since the cursor was not declared")
+	}
+	if _, err = db.Exec(`DECLARE "cur" CURSOR WITH HOLD FOR SELECT id FROM users`); err != nil {
+		t.Fatalf("error '%s' was not expected, while declaring a cursor", err)
+	}
+	if _, err = db.Exec(`DECLARE cur CURSOR FOR SELECT id FROM users`); err == nil {
+		t.Error("expected an error, since the cursor was already declared")
+	}
+
+	expected := "expected cursor to be closed, but it was not: ExpectedCursor => expecting cursor 'cur' to be declared, fetched and closed"
+	if err := mock.ExpectationsWereMet(); err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s', but got '%v'", expected, err)
+	}
+}
@@ -23,16 +23,29 @@ type mockDriver struct {
 }
 
 func (d *mockDriver) Open(dsn string) (driver.Conn, error) {
+	cn, err := d.open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	cn.connected()
+	return cn.driverConn(), nil
+}
+
+func (d *mockDriver) open(dsn string) (*conn, error) {
 	d.Lock()
 	defer d.Unlock()
 
 	c, ok := d.conns[dsn]
 	if !ok {
-		return c, fmt.Errorf("expected a connection to be available, but it is not")
+		return nil, fmt.Errorf("expected a connection to be available, but it is not")
 	}
 
 	c.opened++
-	return c, nil
+	c.connections++
+	if c.opened > c.peakOpened {
+		c.peakOpened = c.opened
+	}
+	return &conn{sqlmock: c, id: c.connections}, nil
 }
 
 // New creates sqlmock database connection and a mock to manage expectations.
@@ -0,0 +1,10 @@
+// +build !go1.8
+
+package sqlmock
+
+import "database/sql/driver"
+
+// driverConn returns the connection as opened by the driver
+func (c *conn) driverConn() driver.Conn {
+	return c
+}
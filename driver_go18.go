@@ -0,0 +1,79 @@
+// +build go1.8
+
+package sqlmock
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// driverConn returns the connection as opened by the driver,
+// hiding legacy interfaces if requested by ContextOnlyDriverOption
+func (c *conn) driverConn() driver.Conn {
+	if c.contextOnly {
+		return &contextOnlyConn{conn: c}
+	}
+	return c
+}
+
+// unwrapConn returns the mock connection behind a driver connection
+func unwrapConn(dc driver.Conn) *conn {
+	if c, ok := dc.(*contextOnlyConn); ok {
+		return c.conn
+	}
+	return dc.(*conn)
+}
+
+// contextOnlyConn exposes a mock connection without
+// driver.Queryer and driver.Execer implementations
+type contextOnlyConn struct {
+	conn *conn
+}
+
+func (c *contextOnlyConn) Prepare(query string) (driver.Stmt, error) {
+	return c.conn.Prepare(query)
+}
+
+func (c *contextOnlyConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *contextOnlyConn) Begin() (driver.Tx, error) {
+	return c.conn.Begin()
+}
+
+func (c *contextOnlyConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.conn.PrepareContext(ctx, query)
+}
+
+func (c *contextOnlyConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.conn.BeginTx(ctx, opts)
+}
+
+func (c *contextOnlyConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.conn.QueryContext(ctx, query, args)
+}
+
+func (c *contextOnlyConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.conn.ExecContext(ctx, query, args)
+}
+
+func (c *contextOnlyConn) Ping(ctx context.Context) error {
+	return c.conn.Ping(ctx)
+}
+
+func (c *contextOnlyConn) ResetSession(ctx context.Context) error {
+	return c.conn.ResetSession(ctx)
+}
+
+func (c *contextOnlyConn) CheckNamedValue(nv *driver.NamedValue) error {
+	return c.conn.CheckNamedValue(nv)
+}
+
+func (c *contextOnlyConn) IsValid() bool {
+	return c.conn.IsValid()
+}
+
+func (c *contextOnlyConn) WaitForNotification(ctx context.Context) (*Notification, error) {
+	return c.conn.WaitForNotification(ctx)
+}
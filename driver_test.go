@@ -4,7 +4,9 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 )
 
 type void struct{}
@@ -130,3 +132,34 @@ func TestDuplicateNewDSN(t *testing.T) {
 		t.Error("expected error on NewWithDSN")
 	}
 }
+
+func TestMaxConcurrentConnections(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	db.SetMaxOpenConns(2)
+	mock.MatchExpectationsInOrder(false)
+	for i := 0; i < 6; i++ {
+		mock.ExpectExec("UPDATE users").WillDelayFor(10 * time.Millisecond).WillReturnResult(NewResult(0, 1))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			if _, err := db.Exec("UPDATE users SET name = ? WHERE id = ?", "john", id); err != nil {
+				t.Errorf("error '%s' was not expected, while updating a row", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := mock.MaxConcurrentConnections(); n != 2 {
+		t.Errorf("expected at most 2 concurrent connections, but got %d", n)
+	}
+}
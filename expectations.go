@@ -3,6 +3,8 @@ package sqlmock
 import (
 	"database/sql/driver"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -16,6 +18,13 @@ type expectation interface {
 	String() string
 }
 
+// Expectation is an expectation set up by Sqlmock, like
+// *ExpectedExec or *ExpectedQuery, which may be grouped by
+// ExpectPhase. It is implemented by sqlmock expectations only.
+type Expectation interface {
+	expectation
+}
+
 // common expectation struct
 // satisfies the expectation interface
 type commonExpectation struct {
@@ -53,7 +62,9 @@ func (e *ExpectedClose) String() string {
 // returned by *Sqlmock.ExpectBegin.
 type ExpectedBegin struct {
 	commonExpectation
-	delay time.Duration
+	delay    time.Duration
+	readOnly bool
+	tx       int // transaction begun once matched
 }
 
 // WillReturnError allows to set an error for *sql.DB.Begin action
@@ -65,12 +76,25 @@ func (e *ExpectedBegin) WillReturnError(err error) *ExpectedBegin {
 // String returns string representation
 func (e *ExpectedBegin) String() string {
 	msg := "ExpectedBegin => expecting database transaction Begin"
+	if e.readOnly {
+		msg += " of a read-only transaction"
+	}
 	if e.err != nil {
 		msg += fmt.Sprintf(", which should return error: %s", e.err)
 	}
 	return msg
 }
 
+// ReadOnly expects the transaction to be started with read-only
+// sql.TxOptions. Regardless of this expectation, any INSERT, UPDATE,
+// DELETE, MERGE, REPLACE or UPSERT statement, also after a leading
+// WITH clause, executed in a read-only transaction fails, like it
+// would on a real database.
+func (e *ExpectedBegin) ReadOnly() *ExpectedBegin {
+	e.readOnly = true
+	return e
+}
+
 // WillDelayFor allows to specify duration for which it will delay
 // result. May be used together with Context
 func (e *ExpectedBegin) WillDelayFor(duration time.Duration) *ExpectedBegin {
@@ -82,6 +106,7 @@ func (e *ExpectedBegin) WillDelayFor(duration time.Duration) *ExpectedBegin {
 // returned by *Sqlmock.ExpectCommit.
 type ExpectedCommit struct {
 	commonExpectation
+	lastStep expectation // last step of the transaction declared by ExpectTx
 }
 
 // ExpectedPing is used to manage *sql.Ping expectation
@@ -148,10 +173,23 @@ func (e *ExpectedRollback) String() string {
 // Returned by *Sqlmock.ExpectQuery.
 type ExpectedQuery struct {
 	queryBasedExpectation
-	rows             driver.Rows
-	delay            time.Duration
-	rowsMustBeClosed bool
-	rowsWereClosed   bool
+	rows              driver.Rows
+	rowsFunc          func(query string, args []namedValue) (*Rows, error)
+	rowsSeq           []*Rows // rows of consecutive calls, the last ones repeat
+	delay             time.Duration
+	rowsMustBeClosed  bool
+	rowsWereClosed    bool
+	rowsMustBeDrained bool
+	rowsNotDrained    bool // some rows were closed before they were drained
+	nextTimings       []time.Time
+	storedTable       string
+	storedColumns     []string
+	singleRow         bool
+	manyRowsRead      bool
+	rowsConsumed      int
+	rowsToConsume     int
+	mustConsumeRows   bool
+	batchesFetched    int
 }
 
 // WithArgs will match given expected args to actual database query arguments.
@@ -162,18 +200,273 @@ func (e *ExpectedQuery) WithArgs(args ...driver.Value) *ExpectedQuery {
 	return e
 }
 
+// WithArgsSlice is the same as WithArgs, but takes the expected args
+// as a slice, like one built by a table-driven test. An empty slice
+// expects the query to be called without arguments.
+func (e *ExpectedQuery) WithArgsSlice(args []driver.Value) *ExpectedQuery {
+	if args == nil {
+		args = []driver.Value{}
+	}
+	e.args = args
+	return e
+}
+
+// WithSensitiveArgs marks arguments of this query at the given ordinal
+// positions, starting from 1, as sensitive. Ordinals are recorded in
+// the Call log, so that tooling can verify the arguments were redacted
+// from logs, see Call.RedactedArgs and OnMatchOption.
+func (e *ExpectedQuery) WithSensitiveArgs(ordinals ...int) *ExpectedQuery {
+	e.sensitive = ordinals
+	return e
+}
+
 // RowsWillBeClosed expects this query rows to be closed.
 func (e *ExpectedQuery) RowsWillBeClosed() *ExpectedQuery {
 	e.rowsMustBeClosed = true
 	return e
 }
 
+// RowsWillBeDrained expects this query rows to be fully read,
+// until Next reports there are no more rows in the last result
+// set, before the rows are closed.
+func (e *ExpectedQuery) RowsWillBeDrained() *ExpectedQuery {
+	e.rowsMustBeDrained = true
+	return e
+}
+
+// RowsWillBeConsumed expects exactly n rows, returned for this query,
+// to be read by the consumer, for example every id returned by an
+// "INSERT ... RETURNING id" query, which inserts several rows.
+// Otherwise the query is reported by ExpectationsWereMet.
+func (e *ExpectedQuery) RowsWillBeConsumed(n int) *ExpectedQuery {
+	e.rowsToConsume = n
+	e.mustConsumeRows = true
+	return e
+}
+
+// ExpectsSingleRow declares that this query is expected to return
+// a single row, as read by QueryRow. If the consumer used Query
+// instead and read a second row, rows.Next fails and the query is
+// reported by ExpectationsWereMet.
+func (e *ExpectedQuery) ExpectsSingleRow() *ExpectedQuery {
+	e.singleRow = true
+	return e
+}
+
+// RowsDrained returns whether the rows returned for this query
+// were fully read before they were closed. When the query is
+// expected more than once, every returned rows must have been
+// drained before they were closed.
+func (e *ExpectedQuery) RowsDrained() bool {
+	e.Lock()
+	defer e.Unlock()
+	return e.rowsWereClosed && !e.rowsNotDrained
+}
+
+// RowsConsumed returns the number of rows returned for this
+// query, which were read by the consumer, across all result sets.
+// A row with an error set by RowError is not counted, since
+// database/sql stops at the error, without the row being scanned.
+func (e *ExpectedQuery) RowsConsumed() int {
+	e.Lock()
+	defer e.Unlock()
+	return e.rowsConsumed
+}
+
+// BatchesFetched returns the number of batches of rows returned
+// for this query, which were fetched by the consumer, see
+// Rows.BatchSize.
+func (e *ExpectedQuery) BatchesFetched() int {
+	e.Lock()
+	defer e.Unlock()
+	return e.batchesFetched
+}
+
+// rowsWereMet checks the expectations on the rows returned for
+// this query, once they were read and closed.
+func (e *ExpectedQuery) rowsWereMet() error {
+	e.Lock()
+	defer e.Unlock()
+	if e.rowsMustBeClosed && !e.rowsWereClosed {
+		return fmt.Errorf("expected query rows to be closed, but it was not: %s", e)
+	}
+	if e.rowsMustBeDrained && (!e.rowsWereClosed || e.rowsNotDrained) {
+		return fmt.Errorf("expected query rows to be drained before they were closed, but they were not: %s", e)
+	}
+	if e.mustConsumeRows && e.rowsConsumed != e.rowsToConsume {
+		return fmt.Errorf("expected %d query rows to be consumed, but %d were read: %s", e.rowsToConsume, e.rowsConsumed, e)
+	}
+	if e.manyRowsRead {
+		return fmt.Errorf("expected query to return a single row, but more rows were read: %s", e)
+	}
+	return nil
+}
+
+// WillReturnStoredRows arranges for this query to return all the
+// rows stored in the table by execs expected with WillInsertInto,
+// as seen by the connection: rows inserted in auto-commit mode or
+// by committed transactions, followed by rows inserted within the
+// current transaction. This allows to build read-your-writes fakes.
+// Every stored row must have a value for each of the given columns.
+func (e *ExpectedQuery) WillReturnStoredRows(table string, columns ...string) *ExpectedQuery {
+	e.storedTable = table
+	e.storedColumns = columns
+	return e
+}
+
+// NextTimings returns the time of every rows.Next call made by
+// the driver on rows returned for this query, including the call
+// which reported there were no more rows. Since database/sql reads
+// a row only when the consumer asks for it, spacing between the
+// timings shows whether the consumer applied backpressure.
+func (e *ExpectedQuery) NextTimings() []time.Time {
+	e.Lock()
+	defer e.Unlock()
+	timings := make([]time.Time, len(e.nextTimings))
+	copy(timings, e.nextTimings)
+	return timings
+}
+
+// RequiresHint expects this query to contain an optimizer hint
+// comment like "/*+ INDEX(users idx_name) */", which includes
+// the given hint text. Hints found in queries are also recorded
+// in the Call log.
+func (e *ExpectedQuery) RequiresHint(hint string) *ExpectedQuery {
+	e.constraints = append(e.constraints, requiresHint(hint))
+	return e
+}
+
+// RequiresIndexHint expects this query to name the given index in
+// an SQL comment, like "/* index: idx_users_email */" or an optimizer
+// hint, and to have a WHERE clause shaped to use the index, which is
+// checked by a simple heuristic: one of the top level AND terms must
+// compare the leading column of the index. A column wrapped in a
+// function and an OR in the WHERE clause are rejected, while SQL is
+// not parsed, so a comparison nested in a subquery is accepted. The
+// leading column is given along with the index, since columns of the
+// index cannot be told from its name.
+func (e *ExpectedQuery) RequiresIndexHint(index, leadingColumn string) *ExpectedQuery {
+	e.constraints = append(e.constraints, requiresIndexHint(index, leadingColumn))
+	return e
+}
+
+// RequiresSQLComment expects this query to end with an SQL comment in
+// the sqlcommenter format, like "/*application='x',controller='y'*/",
+// which has all the given tags with the given values. Other tags of
+// the comment are ignored, so that tags added by middleware can be
+// verified without matching the whole comment.
+func (e *ExpectedQuery) RequiresSQLComment(tags map[string]string) *ExpectedQuery {
+	e.constraints = append(e.constraints, requiresSQLComment(tags))
+	return e
+}
+
+// WithTrailingArgs will match given expected args to the arguments
+// bound to the trailing predicate ignored by IgnoreTrailingPredicateOption.
+func (e *ExpectedQuery) WithTrailingArgs(args ...driver.Value) *ExpectedQuery {
+	e.trailingArgs = args
+	return e
+}
+
+// SkipTableAllowlist excludes this query from the table allowlist
+// verification set up by TableAllowlistOption, which is useful for
+// complex queries the table scan cannot handle.
+func (e *ExpectedQuery) SkipTableAllowlist() *ExpectedQuery {
+	e.skipTableAllowlist = true
+	return e
+}
+
+// InTransaction expects this query to be called within a transaction.
+func (e *ExpectedQuery) InTransaction() *ExpectedQuery {
+	e.constraints = append(e.constraints, inTransaction)
+	return e
+}
+
+// RequireContext expects this query to be called with a context, like
+// by *sql.DB.QueryContext, rather than by *sql.DB.Query. A context.Background
+// or context.TODO does not satisfy it, since database/sql passes
+// context.Background to the driver for calls without a context.
+func (e *ExpectedQuery) RequireContext() *ExpectedQuery {
+	e.constraints = append(e.constraints, withContext)
+	return e
+}
+
+// InAutoCommit expects this query to be called in auto-commit
+// mode, that is outside of any transaction.
+func (e *ExpectedQuery) InAutoCommit() *ExpectedQuery {
+	e.constraints = append(e.constraints, inAutoCommit)
+	return e
+}
+
+// Times allows to expect the query to be called n times,
+// the expectation is fulfilled only after n calls were matched.
+// Note that n must be greater than zero.
+func (e *ExpectedQuery) Times(n int) *ExpectedQuery {
+	e.times = n
+	return e
+}
+
 // WillReturnError allows to set an error for expected database query
 func (e *ExpectedQuery) WillReturnError(err error) *ExpectedQuery {
 	e.err = err
 	return e
 }
 
+// WillReturnErrorsSequence allows to set an error for each of the
+// repeated calls of this query, in order. A nil entry means the call
+// succeeds, like for an attempt succeeding after a few failures.
+// Unless Times was set, the query is expected to be called once per
+// error in sequence.
+func (e *ExpectedQuery) WillReturnErrorsSequence(errs []error) *ExpectedQuery {
+	e.errs = errs
+	if e.times == 0 {
+		e.times = len(errs)
+	}
+	return e
+}
+
+// WillReturnStaleRows models a lagging read replica: the first
+// reads calls of this query return the stale rows, as the write
+// was not replicated yet, then the fresh rows are returned. Expected
+// after an exec on the primary, this allows to test read-after-write
+// handling, like falling back to read from the primary. Reads are
+// counted from the first call matched by this expectation. Unless
+// Times was set, the query is expected to be called reads+1 times.
+// Like WillReturnRows, it replaces the rows set for this query before,
+// and is replaced by rows set afterwards, which keeps the calls expected.
+func (e *ExpectedQuery) WillReturnStaleRows(reads int, stale, fresh *Rows) *ExpectedQuery {
+	seq := make([]*Rows, 0, reads+1)
+	for i := 0; i < reads; i++ {
+		seq = append(seq, stale)
+	}
+	e.rows = nil
+	e.rowsSeq = append(seq, fresh)
+	if e.times == 0 {
+		e.times = reads + 1
+	}
+	return e
+}
+
+// WillSkipFastPath makes the connection return driver.ErrSkip
+// for a call matching this expectation, which was not made on a
+// prepared statement. Like for drivers which do not support such
+// calls, database/sql then falls back to prepare the statement on
+// the same connection and to run it, which is matched by this
+// expectation again. The statement is prepared implicitly, without
+// an ExpectPrepare. Calls on statements prepared by the code under
+// test are matched as usual.
+func (e *ExpectedQuery) WillSkipFastPath() *ExpectedQuery {
+	e.skipFastPath = true
+	return e
+}
+
+// ActualDelay returns the time the last matched call was actually
+// delayed for, as measured. It is shorter than the duration set by
+// WillDelayFor, if the context of the call was done early, or the
+// delay was clamped by MaxDelayOption.
+func (e *ExpectedQuery) ActualDelay() time.Duration {
+	return e.lastDelay()
+}
+
 // WillDelayFor allows to specify duration for which it will delay
 // result. May be used together with Context
 func (e *ExpectedQuery) WillDelayFor(duration time.Duration) *ExpectedQuery {
@@ -181,11 +474,25 @@ func (e *ExpectedQuery) WillDelayFor(duration time.Duration) *ExpectedQuery {
 	return e
 }
 
+// WillReturnScalarAfter allows to return a single row with a single
+// value, like the result of a slow COUNT(*), once the query was delayed
+// for duration d. Like with WillDelayFor, a query made with a context
+// is cancelled, if the context is done before the delay elapses. The
+// column is named "?column?", the same way PostgreSQL names an
+// aggregate without an alias.
+func (e *ExpectedQuery) WillReturnScalarAfter(value driver.Value, d time.Duration) *ExpectedQuery {
+	return e.WillReturnRows(NewRows([]string{"?column?"}).AddRow(value)).WillDelayFor(d)
+}
+
 // String returns string representation
 func (e *ExpectedQuery) String() string {
 	msg := "ExpectedQuery => expecting Query, QueryContext or QueryRow which:"
 	msg += "\n  - matches sql: '" + e.expectSQL + "'"
 
+	if e.prepared != nil {
+		msg += "\n  - is executed on a prepared statement"
+	}
+
 	if len(e.args) == 0 {
 		msg += "\n  - is without arguments"
 	} else {
@@ -200,6 +507,14 @@ func (e *ExpectedQuery) String() string {
 		msg += fmt.Sprintf("\n  - %s", e.rows)
 	}
 
+	if e.times > 1 {
+		msg += fmt.Sprintf("\n  - should be called %d times, was called %d times", e.times, e.calls)
+	}
+
+	if len(e.errs) > 0 {
+		msg += fmt.Sprintf("\n  - should return a sequence of errors: %v", e.errs)
+	}
+
 	if e.err != nil {
 		msg += fmt.Sprintf("\n  - should return error: %s", e.err)
 	}
@@ -211,8 +526,15 @@ func (e *ExpectedQuery) String() string {
 // Returned by *Sqlmock.ExpectExec.
 type ExpectedExec struct {
 	queryBasedExpectation
-	result driver.Result
-	delay  time.Duration
+	result     driver.Result
+	results    []driver.Result
+	resultFunc func(query string, args []namedValue) (driver.Result, error)
+	insertID   func(args []namedValue) int64
+	autoIncr   bool
+	delay      time.Duration
+	warnings   *Rows
+	warned     int
+	insertInto string
 }
 
 // WithArgs will match given expected args to actual database exec operation arguments.
@@ -223,12 +545,144 @@ func (e *ExpectedExec) WithArgs(args ...driver.Value) *ExpectedExec {
 	return e
 }
 
+// WithArgsSlice is the same as WithArgs, but takes the expected args
+// as a slice, like one built by a table-driven test. An empty slice
+// expects the exec to be called without arguments.
+func (e *ExpectedExec) WithArgsSlice(args []driver.Value) *ExpectedExec {
+	if args == nil {
+		args = []driver.Value{}
+	}
+	e.args = args
+	return e
+}
+
+// WithSensitiveArgs marks arguments of this exec at the given ordinal
+// positions, starting from 1, as sensitive. Ordinals are recorded in
+// the Call log, so that tooling can verify the arguments were redacted
+// from logs, see Call.RedactedArgs and OnMatchOption.
+func (e *ExpectedExec) WithSensitiveArgs(ordinals ...int) *ExpectedExec {
+	e.sensitive = ordinals
+	return e
+}
+
+// RequiresHint expects this exec to contain an optimizer hint
+// comment like "/*+ INDEX(users idx_name) */", which includes
+// the given hint text. Hints found in queries are also recorded
+// in the Call log.
+func (e *ExpectedExec) RequiresHint(hint string) *ExpectedExec {
+	e.constraints = append(e.constraints, requiresHint(hint))
+	return e
+}
+
+// RequiresIndexHint expects this exec to name the given index in
+// an SQL comment, like "/* index: idx_users_email */" or an optimizer
+// hint, and to have a WHERE clause shaped to use the index, which is
+// checked by a simple heuristic: one of the top level AND terms must
+// compare the leading column of the index. A column wrapped in a
+// function and an OR in the WHERE clause are rejected, while SQL is
+// not parsed, so a comparison nested in a subquery is accepted. The
+// leading column is given along with the index, since columns of the
+// index cannot be told from its name.
+func (e *ExpectedExec) RequiresIndexHint(index, leadingColumn string) *ExpectedExec {
+	e.constraints = append(e.constraints, requiresIndexHint(index, leadingColumn))
+	return e
+}
+
+// RequiresSQLComment expects this exec to end with an SQL comment in
+// the sqlcommenter format, like "/*application='x',controller='y'*/",
+// which has all the given tags with the given values. Other tags of
+// the comment are ignored, so that tags added by middleware can be
+// verified without matching the whole comment.
+func (e *ExpectedExec) RequiresSQLComment(tags map[string]string) *ExpectedExec {
+	e.constraints = append(e.constraints, requiresSQLComment(tags))
+	return e
+}
+
+// WithTrailingArgs will match given expected args to the arguments
+// bound to the trailing predicate ignored by IgnoreTrailingPredicateOption.
+func (e *ExpectedExec) WithTrailingArgs(args ...driver.Value) *ExpectedExec {
+	e.trailingArgs = args
+	return e
+}
+
+// SkipTableAllowlist excludes this exec from the table allowlist
+// verification set up by TableAllowlistOption, which is useful for
+// complex queries the table scan cannot handle.
+func (e *ExpectedExec) SkipTableAllowlist() *ExpectedExec {
+	e.skipTableAllowlist = true
+	return e
+}
+
+// InTransaction expects this exec to be called within a transaction.
+func (e *ExpectedExec) InTransaction() *ExpectedExec {
+	e.constraints = append(e.constraints, inTransaction)
+	return e
+}
+
+// RequireContext expects this exec to be called with a context, like
+// by *sql.DB.ExecContext, rather than by *sql.DB.Exec. A context.Background
+// or context.TODO does not satisfy it, since database/sql passes
+// context.Background to the driver for calls without a context.
+func (e *ExpectedExec) RequireContext() *ExpectedExec {
+	e.constraints = append(e.constraints, withContext)
+	return e
+}
+
+// InAutoCommit expects this exec to be called in auto-commit
+// mode, that is outside of any transaction.
+func (e *ExpectedExec) InAutoCommit() *ExpectedExec {
+	e.constraints = append(e.constraints, inAutoCommit)
+	return e
+}
+
+// Times allows to expect the exec to be called n times,
+// the expectation is fulfilled only after n calls were matched.
+// Note that n must be greater than zero.
+func (e *ExpectedExec) Times(n int) *ExpectedExec {
+	e.times = n
+	return e
+}
+
 // WillReturnError allows to set an error for expected database exec action
 func (e *ExpectedExec) WillReturnError(err error) *ExpectedExec {
 	e.err = err
 	return e
 }
 
+// WillReturnErrorsSequence allows to set an error for each of the
+// repeated calls of this exec, in order. A nil entry means the call
+// succeeds, like for an attempt succeeding after a few failures.
+// Unless Times was set, the exec is expected to be called once per
+// error in sequence.
+func (e *ExpectedExec) WillReturnErrorsSequence(errs []error) *ExpectedExec {
+	e.errs = errs
+	if e.times == 0 {
+		e.times = len(errs)
+	}
+	return e
+}
+
+// WillSkipFastPath makes the connection return driver.ErrSkip
+// for a call matching this expectation, which was not made on a
+// prepared statement. Like for drivers which do not support such
+// calls, database/sql then falls back to prepare the statement on
+// the same connection and to run it, which is matched by this
+// expectation again. The statement is prepared implicitly, without
+// an ExpectPrepare. Calls on statements prepared by the code under
+// test are matched as usual.
+func (e *ExpectedExec) WillSkipFastPath() *ExpectedExec {
+	e.skipFastPath = true
+	return e
+}
+
+// ActualDelay returns the time the last matched call was actually
+// delayed for, as measured. It is shorter than the duration set by
+// WillDelayFor, if the context of the call was done early, or the
+// delay was clamped by MaxDelayOption.
+func (e *ExpectedExec) ActualDelay() time.Duration {
+	return e.lastDelay()
+}
+
 // WillDelayFor allows to specify duration for which it will delay
 // result. May be used together with Context
 func (e *ExpectedExec) WillDelayFor(duration time.Duration) *ExpectedExec {
@@ -241,6 +695,10 @@ func (e *ExpectedExec) String() string {
 	msg := "ExpectedExec => expecting Exec or ExecContext which:"
 	msg += "\n  - matches sql: '" + e.expectSQL + "'"
 
+	if e.prepared != nil {
+		msg += "\n  - is executed on a prepared statement"
+	}
+
 	if len(e.args) == 0 {
 		msg += "\n  - is without arguments"
 	} else {
@@ -262,6 +720,34 @@ func (e *ExpectedExec) String() string {
 		}
 	}
 
+	if e.resultFunc != nil {
+		msg += "\n  - should return Result computed by a func"
+	}
+
+	if e.insertID != nil {
+		msg += "\n  - should return LastInsertId computed by a func"
+	}
+
+	if e.autoIncr {
+		msg += "\n  - should return LastInsertId of the auto-increment counter"
+	}
+
+	if e.warnings != nil {
+		msg += fmt.Sprintf("\n  - should produce %d warnings", len(e.warnings.rows))
+	}
+
+	if len(e.results) > 0 {
+		msg += fmt.Sprintf("\n  - should return a sequence of %d results", len(e.results))
+	}
+
+	if e.times > 1 {
+		msg += fmt.Sprintf("\n  - should be called %d times, was called %d times", e.times, e.calls)
+	}
+
+	if len(e.errs) > 0 {
+		msg += fmt.Sprintf("\n  - should return a sequence of errors: %v", e.errs)
+	}
+
 	if e.err != nil {
 		msg += fmt.Sprintf("\n  - should return error: %s", e.err)
 	}
@@ -278,6 +764,70 @@ func (e *ExpectedExec) WillReturnResult(result driver.Result) *ExpectedExec {
 	return e
 }
 
+// WillReturnVersionConflict arranges for an expected Exec() to
+// affect no rows, as an optimistic locking update does, when the
+// row version given in its "WHERE version = ?" predicate is stale.
+// This allows to test the retry or refresh path of the caller.
+func (e *ExpectedExec) WillReturnVersionConflict() *ExpectedExec {
+	e.result = NewResult(0, 0)
+	return e
+}
+
+// WillInsertInto arranges for an expected Exec() to store its
+// argument values as a row of the given table, so that queries
+// expected with WillReturnStoredRows return it. A row inserted
+// within a transaction is visible only to that transaction until
+// it is committed, and is dropped if it is rolled back. Unless a
+// result was set, the exec returns one affected row.
+func (e *ExpectedExec) WillInsertInto(table string) *ExpectedExec {
+	e.insertInto = table
+	return e
+}
+
+// WillReturnWarnings arranges for an expected Exec() to produce
+// informational rows, like warnings some drivers return for DML.
+// Since database/sql discards anything but the result of an exec,
+// the warnings can only be read by code which calls the driver
+// connection directly, for example with sql.Conn.Raw, as the driver
+// result then implements WarningsResult. Use WarningsProduced to
+// assert that the warnings were produced either way.
+func (e *ExpectedExec) WillReturnWarnings(rows *Rows) *ExpectedExec {
+	e.warnings = rows
+	return e
+}
+
+// WarningsProduced returns the number of times this exec
+// produced the warnings set with WillReturnWarnings.
+func (e *ExpectedExec) WarningsProduced() int {
+	e.Lock()
+	defer e.Unlock()
+	return e.warned
+}
+
+// WillReturnResultsSequence arranges for an expected Exec() to return
+// a different result on each matched call, consuming results in the
+// given order. Unless Times was set, the exec is expected to be
+// called once per each result in the sequence.
+func (e *ExpectedExec) WillReturnResultsSequence(results []driver.Result) *ExpectedExec {
+	e.results = results
+	if e.times == 0 {
+		e.times = len(results)
+	}
+	return e
+}
+
+// WillReturnAutoIncrementId makes the triggered exec take its
+// LastInsertId from the auto-increment counter of the mock, like
+// MySQL does: the counter advances by the rows affected by the exec
+// and LastInsertId is the first of the allocated ids. RowsAffected
+// is taken from the result set with WillReturnResult, which defaults
+// to one affected row. An exec returning an error, or an error result,
+// does not advance the counter, see Sqlmock.AutoIncrementCounter.
+func (e *ExpectedExec) WillReturnAutoIncrementId() *ExpectedExec {
+	e.autoIncr = true
+	return e
+}
+
 // ExpectedPrepare is used to manage *sql.DB.Prepare or *sql.Tx.Prepare expectations.
 // Returned by *Sqlmock.ExpectPrepare.
 type ExpectedPrepare struct {
@@ -288,7 +838,16 @@ type ExpectedPrepare struct {
 	closeErr     error
 	mustBeClosed bool
 	wasClosed    bool
+	stmts        []*statement // statements prepared for this expectation
 	delay        time.Duration
+	conns        []*conn // connections the statement was prepared on
+	constant     map[int]bool
+	constants    map[int]driver.Value // first values bound at constant ordinals
+	numInput     int
+	checksInput  bool
+	inputChecks  int  // arity checks made by database/sql
+	inputsPassed int  // calls which reached the driver after the check
+	fallback     bool // prepared implicitly after a skipped fast path
 }
 
 // WillReturnError allows to set an error for the expected *sql.DB.Prepare or *sql.Tx.Prepare action.
@@ -311,28 +870,108 @@ func (e *ExpectedPrepare) WillDelayFor(duration time.Duration) *ExpectedPrepare
 }
 
 // WillBeClosed expects this prepared statement to
-// be closed.
+// be closed. Regardless of it, a statement closed more than
+// once is reported by ExpectationsWereMet, which is detectable
+// only at the driver level, see CloseCount.
 func (e *ExpectedPrepare) WillBeClosed() *ExpectedPrepare {
 	e.mustBeClosed = true
 	return e
 }
 
+// WithConstantArg expects the argument at the given ordinal
+// position, starting from 1, to be bound to the same value by
+// every exec and query made on statements prepared for this
+// expectation, for example a tenant id, while other arguments
+// vary as the statement is reused.
+func (e *ExpectedPrepare) WithConstantArg(ordinal int) *ExpectedPrepare {
+	if e.constant == nil {
+		e.constant = make(map[int]bool)
+	}
+	e.constant[ordinal] = true
+	return e
+}
+
+// WithNumInput makes statements prepared for this expectation
+// report n placeholders, so that database/sql rejects execs and
+// queries made on them with a different number of arguments,
+// before they reach the driver. See NumInputRejections.
+func (e *ExpectedPrepare) WithNumInput(n int) *ExpectedPrepare {
+	e.numInput = n
+	e.checksInput = true
+	return e
+}
+
+// NumInputRejections returns the number of execs and queries
+// made on statements prepared for this expectation, which were
+// rejected by database/sql once it asked for WithNumInput. Besides
+// a wrong number of arguments, this counts arguments which could
+// not be converted to driver values, since database/sql asks before
+// converting them.
+func (e *ExpectedPrepare) NumInputRejections() int {
+	e.Lock()
+	defer e.Unlock()
+	return e.inputChecks - e.inputsPassed
+}
+
+// CloseCount returns the number of times a statement prepared for
+// this expectation was closed at the driver level. When it was
+// prepared more than once, like again on a new connection, the
+// highest count of a single statement is returned. Since database/sql
+// closes a driver statement only once, regardless of how many times
+// *sql.Stmt.Close is called, a count above one is only possible for
+// code which closes statements of a driver.Conn directly.
+func (e *ExpectedPrepare) CloseCount() int {
+	e.Lock()
+	defer e.Unlock()
+	var count int
+	for _, stmt := range e.stmts {
+		if stmt.closes > count {
+			count = stmt.closes
+		}
+	}
+	return count
+}
+
+// closesWereMet checks that statements prepared for this
+// expectation were closed, if expected, and at most once
+func (e *ExpectedPrepare) closesWereMet() error {
+	e.Lock()
+	defer e.Unlock()
+	if e.mustBeClosed && !e.wasClosed {
+		return fmt.Errorf("expected prepared statement to be closed, but it was not: %s", e)
+	}
+	for _, stmt := range e.stmts {
+		if stmt.closes > 1 {
+			return fmt.Errorf("expected prepared statement to be closed exactly once, but the statement prepared on connection %d was closed %d times: %s", stmt.conn.id, stmt.closes, e)
+		}
+	}
+	return nil
+}
+
 // ExpectQuery allows to expect Query() or QueryRow() on this prepared statement.
 // This method is convenient in order to prevent duplicating sql query string matching.
+// The expected query is linked to this prepared statement: queries made on a
+// statement are matched only if it was prepared for this expectation, while
+// queries made directly on the database are matched regardless, as before.
 func (e *ExpectedPrepare) ExpectQuery() *ExpectedQuery {
 	eq := &ExpectedQuery{}
 	eq.expectSQL = e.expectSQL
 	eq.converter = e.mock.converter
+	eq.prepared = e
 	e.mock.expected = append(e.mock.expected, eq)
 	return eq
 }
 
 // ExpectExec allows to expect Exec() on this prepared statement.
 // This method is convenient in order to prevent duplicating sql query string matching.
+// The expected exec is linked to this prepared statement: execs made on a
+// statement are matched only if it was prepared for this expectation, while
+// execs made directly on the database are matched regardless, as before.
 func (e *ExpectedPrepare) ExpectExec() *ExpectedExec {
 	eq := &ExpectedExec{}
 	eq.expectSQL = e.expectSQL
 	eq.converter = e.mock.converter
+	eq.prepared = e
 	e.mock.expected = append(e.mock.expected, eq)
 	return eq
 }
@@ -358,8 +997,176 @@ func (e *ExpectedPrepare) String() string {
 type queryBasedExpectation struct {
 	commonExpectation
 	expectSQL string
+	expectRe  *regexp.Regexp
 	converter driver.ValueConverter
 	args      []driver.Value
+	times     int
+	calls     int
+	prepared  *ExpectedPrepare
+
+	constraints []func(call *Call) error
+
+	skipTableAllowlist bool
+
+	trailingArgs []driver.Value
+
+	errs []error
+
+	sensitive []int
+
+	actualDelay time.Duration
+
+	skipFastPath bool
+}
+
+// delayed records the time the last matched call
+// was delayed for, since it started to wait
+func (e *queryBasedExpectation) delayed(start time.Time) {
+	e.Lock()
+	e.actualDelay = time.Since(start)
+	e.Unlock()
+}
+
+// lastDelay returns the time the last matched call was delayed for
+func (e *queryBasedExpectation) lastDelay() time.Duration {
+	e.Lock()
+	defer e.Unlock()
+	return e.actualDelay
+}
+
+// errorsExhausted reports whether the expectation was called
+// more times than errors were set in sequence
+func (e *queryBasedExpectation) errorsExhausted() bool {
+	return len(e.errs) > 0 && e.calls >= len(e.errs)
+}
+
+// callError returns the error to be returned by the current call
+func (e *queryBasedExpectation) callError() error {
+	if len(e.errs) > 0 {
+		return e.errs[e.calls]
+	}
+	return e.err
+}
+
+// trailingArgsMatch matches arguments bound to the trailing
+// predicate ignored by IgnoreTrailingPredicateOption
+func (e *queryBasedExpectation) trailingArgsMatch(args []namedValue) error {
+	if e.trailingArgs == nil {
+		return nil
+	}
+	rebased := make([]namedValue, len(args))
+	for i, arg := range args {
+		arg.Ordinal = i + 1
+		rebased[i] = arg
+	}
+	trailing := &queryBasedExpectation{args: e.trailingArgs, converter: e.converter}
+	return trailing.attemptArgMatch(rebased)
+}
+
+// callMatches checks whether the call satisfies
+// all constraints set for this expectation
+func (e *queryBasedExpectation) callMatches(call *Call) error {
+	for _, constraint := range e.constraints {
+		if err := constraint(call); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func inTransaction(call *Call) error {
+	if !call.InTx {
+		return fmt.Errorf("was expected to be called within a transaction")
+	}
+	return nil
+}
+
+func withContext(call *Call) error {
+	if !call.WithContext {
+		return fmt.Errorf("was expected to be called with a context")
+	}
+	return nil
+}
+
+func inAutoCommit(call *Call) error {
+	if call.InTx {
+		return fmt.Errorf("was expected to be called in auto-commit mode, outside of a transaction")
+	}
+	return nil
+}
+
+func requiresHint(hint string) func(call *Call) error {
+	return func(call *Call) error {
+		for _, h := range call.Hints {
+			if strings.Contains(h, hint) {
+				return nil
+			}
+		}
+		return fmt.Errorf("was expected to contain optimizer hint '%s', but got hints %q", hint, call.Hints)
+	}
+}
+
+func requiresIndexHint(index, column string) func(call *Call) error {
+	return func(call *Call) error {
+		comments := queryComments(call.Query)
+		found := false
+		for _, comment := range comments {
+			if strings.Contains(comment, index) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("was expected to contain index '%s' in a comment, but got comments %q", index, comments)
+		}
+		return indexFriendly(call.Query, column)
+	}
+}
+
+func requiresSQLComment(expected map[string]string) func(call *Call) error {
+	keys := make([]string, 0, len(expected))
+	for key := range expected {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return func(call *Call) error {
+		tags, err := commentTags(call.Query)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			value, ok := tags[key]
+			if !ok {
+				return fmt.Errorf("was expected to have SQL comment tag %s='%s', but it is missing", key, expected[key])
+			}
+			if value != expected[key] {
+				return fmt.Errorf("was expected to have SQL comment tag %s='%s', but got '%s'", key, expected[key], value)
+			}
+		}
+		return nil
+	}
+}
+
+// stmtMatches checks whether a call made on the given statement
+// satisfies the prepared statement this expectation was linked to.
+// Calls made directly on connection, with a nil statement, match
+// as they always did, only calls on statements are linked
+func (e *queryBasedExpectation) stmtMatches(stmt *statement) error {
+	if e.prepared == nil || stmt == nil {
+		return nil
+	}
+	if stmt.ex != e.prepared {
+		return fmt.Errorf("was expected to be executed on a statement prepared with sql: '%s'", e.prepared.expectSQL)
+	}
+	return nil
+}
+
+// trigger registers a matched call, the expectation gets
+// triggered once it was called the expected number of times
+func (e *queryBasedExpectation) trigger() {
+	e.calls++
+	e.triggered = e.calls >= e.times
 }
 
 func (e *queryBasedExpectation) attemptArgMatch(args []namedValue) (err error) {
@@ -12,6 +12,7 @@ import (
 // by the triggered query
 func (e *ExpectedQuery) WillReturnRows(rows *Rows) *ExpectedQuery {
 	e.rows = &rowSets{sets: []*Rows{rows}, ex: e}
+	e.rowsSeq = nil
 	return e
 }
 
@@ -19,6 +20,9 @@ func (e *queryBasedExpectation) argsMatches(args []namedValue) error {
 	if nil == e.args {
 		return nil
 	}
+	if u, ok := unordered(e.args); ok {
+		return u.match(e.converter, args)
+	}
 	if len(args) != len(e.args) {
 		return fmt.Errorf("expected %d, but got %d arguments", len(e.args), len(args))
 	}
@@ -28,7 +32,7 @@ func (e *queryBasedExpectation) argsMatches(args []namedValue) error {
 		if ok {
 			// @TODO: does it make sense to pass value instead of named value?
 			if !matcher.Match(v.Value) {
-				return fmt.Errorf("matcher %T could not match %d argument %T - %+v", matcher, k, args[k], args[k])
+				return argumentMismatch(matcher, k, args[k], v.Value)
 			}
 			continue
 		}
@@ -4,6 +4,7 @@ package sqlmock
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"reflect"
 )
@@ -16,13 +17,68 @@ func (e *ExpectedQuery) WillReturnRows(rows ...*Rows) *ExpectedQuery {
 		sets[i] = r
 	}
 	e.rows = &rowSets{sets: sets, ex: e}
+	e.rowsSeq = nil
 	return e
 }
 
+// WillReturnRowsFunc allows to build the rows returned by the
+// triggered query from the actual query and its arguments, for
+// example to filter a fixture with Rows.Filter by the bound ids.
+// The func is called once for every triggered query and an error
+// it returns is returned from the query as is, while the expectation
+// is fulfilled only once fn succeeds. Rows it returns are subject to
+// the same column checks as rows set with WillReturnRows or
+// WillReturnStaleRows, over which the func takes precedence.
+func (e *ExpectedQuery) WillReturnRowsFunc(fn func(query string, args []driver.NamedValue) (*Rows, error)) *ExpectedQuery {
+	e.rowsFunc = func(query string, args []namedValue) (*Rows, error) {
+		return fn(query, toDriverNamedValues(args))
+	}
+	return e
+}
+
+// WillReturnResultFunc allows to compute the result of the triggered
+// exec from the actual query and its arguments, for example to return
+// as many affected rows as there were ids bound to an IN list, or to
+// branch on which statement matched a shared pattern. An error returned
+// by fn is returned from the exec as is, and the expectation is fulfilled
+// only once fn succeeds. The func takes precedence over results set with
+// WillReturnResult.
+func (e *ExpectedExec) WillReturnResultFunc(fn func(query string, args []driver.NamedValue) (driver.Result, error)) *ExpectedExec {
+	e.resultFunc = func(query string, args []namedValue) (driver.Result, error) {
+		return fn(query, toDriverNamedValues(args))
+	}
+	return e
+}
+
+// WillReturnLastInsertIdFunc allows to compute LastInsertId of the
+// triggered exec from its arguments, for example as a hash of the
+// inserted values, so that retried inserts get the same id, like
+// content-addressed inserts do. RowsAffected is taken from the result
+// set with WillReturnResult, which defaults to one affected row.
+func (e *ExpectedExec) WillReturnLastInsertIdFunc(fn func(args []driver.NamedValue) int64) *ExpectedExec {
+	e.insertID = func(args []namedValue) int64 {
+		return fn(toDriverNamedValues(args))
+	}
+	return e
+}
+
+// toDriverNamedValues converts arguments to the
+// named values, as given by database/sql
+func toDriverNamedValues(args []namedValue) []driver.NamedValue {
+	namedArgs := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		namedArgs[i] = driver.NamedValue(arg)
+	}
+	return namedArgs
+}
+
 func (e *queryBasedExpectation) argsMatches(args []namedValue) error {
 	if nil == e.args {
 		return nil
 	}
+	if u, ok := unordered(e.args); ok {
+		return u.match(e.converter, args)
+	}
 	if len(args) != len(e.args) {
 		return fmt.Errorf("expected %d, but got %d arguments", len(e.args), len(args))
 	}
@@ -32,7 +88,7 @@ func (e *queryBasedExpectation) argsMatches(args []namedValue) error {
 		matcher, ok := e.args[k].(Argument)
 		if ok {
 			if !matcher.Match(v.Value) {
-				return fmt.Errorf("matcher %T could not match %d argument %T - %+v", matcher, k, args[k], args[k])
+				return argumentMismatch(matcher, k, args[k], v.Value)
 			}
 			continue
 		}
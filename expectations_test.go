@@ -65,6 +65,19 @@ func TestQueryExpectationArgComparison(t *testing.T) {
 	}
 }
 
+func TestExpectationWithArgsSlice(t *testing.T) {
+	ids := []driver.Value{int64(3), int64(5)}
+	e := (&ExpectedQuery{}).WithArgsSlice(ids)
+	if !reflect.DeepEqual(e.args, []driver.Value{int64(3), int64(5)}) {
+		t.Errorf("expected args to be spread from the slice, but got %+v", e.args)
+	}
+
+	ex := (&ExpectedExec{}).WithArgsSlice(nil)
+	if ex.args == nil || len(ex.args) != 0 {
+		t.Errorf("expected an empty slice to expect no arguments, but got %+v", ex.args)
+	}
+}
+
 func TestQueryExpectationArgComparisonBool(t *testing.T) {
 	var e *queryBasedExpectation
 
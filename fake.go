@@ -0,0 +1,102 @@
+package sqlmock
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// FakeKind is a kind of fake data, generated
+// for a column by GenerateFakeRows.
+type FakeKind int
+
+// Kinds of fake column data
+const (
+	FakeName      FakeKind = iota // full name, like "Grace Hopper"
+	FakeEmail                     // email address, like "grace.hopper@example.com"
+	FakeIntRange                  // int64 between Min and Max, inclusive
+	FakeTimeRange                 // time.Time between From and To, in UTC
+)
+
+// FakeColumnSpec declares a column of rows generated by
+// GenerateFakeRows and the kind of fake data it holds.
+type FakeColumnSpec struct {
+	Name string
+	Kind FakeKind
+
+	// Min and Max bound values of a FakeIntRange column.
+	Min, Max int64
+
+	// From and To bound values of a FakeTimeRange column,
+	// values are truncated to seconds.
+	From, To time.Time
+}
+
+var (
+	fakeFirstNames = []string{"Ada", "Alan", "Barbara", "Dennis", "Edsger", "Frances", "Grace", "John", "Ken", "Leslie", "Margaret", "Niklaus", "Radia", "Tony"}
+	fakeLastNames  = []string{"Allen", "Dijkstra", "Hamilton", "Hoare", "Hopper", "Lamport", "Liskov", "Lovelace", "McCarthy", "Perlman", "Ritchie", "Thompson", "Turing", "Wirth"}
+)
+
+// GenerateFakeRows builds rows with n rows of plausible fake
+// data for the given columns. The data is deterministic for
+// a given seed, so that it can be used for fixtures. It panics
+// if a range of a column spec is empty or the kind is unknown.
+func GenerateFakeRows(columns []FakeColumnSpec, n int, seed int64) *Rows {
+	cols := make([]string, len(columns))
+	for i, col := range columns {
+		cols[i] = col.Name
+	}
+	rows := NewRows(cols)
+
+	rnd := rand.New(rand.NewSource(seed))
+	for r := 0; r < n; r++ {
+		values := make([]driver.Value, len(columns))
+		for i, col := range columns {
+			values[i] = col.fake(rnd)
+		}
+		rows.AddRow(values...)
+	}
+	return rows
+}
+
+// fake generates a single value of the column
+func (col FakeColumnSpec) fake(rnd *rand.Rand) driver.Value {
+	switch col.Kind {
+	case FakeName:
+		return fakeFirstNames[rnd.Intn(len(fakeFirstNames))] + " " + fakeLastNames[rnd.Intn(len(fakeLastNames))]
+	case FakeEmail:
+		first := fakeFirstNames[rnd.Intn(len(fakeFirstNames))]
+		last := fakeLastNames[rnd.Intn(len(fakeLastNames))]
+		return strings.ToLower(fmt.Sprintf("%s.%s%d@example.com", first, last, rnd.Intn(100)))
+	case FakeIntRange:
+		if col.Max < col.Min {
+			panic(fmt.Sprintf("column %q: expected Min %d to be less or equal to Max %d", col.Name, col.Min, col.Max))
+		}
+		return fakeInt63Range(rnd, col.Min, col.Max)
+	case FakeTimeRange:
+		from, to := col.From.Unix(), col.To.Unix()
+		if to < from {
+			panic(fmt.Sprintf("column %q: expected From %s to be before To %s", col.Name, col.From, col.To))
+		}
+		return time.Unix(fakeInt63Range(rnd, from, to), 0).UTC()
+	}
+	panic(fmt.Sprintf("column %q: unknown fake kind %d", col.Name, col.Kind))
+}
+
+// fakeInt63Range generates an int64 between min and max, inclusive.
+// Spans wider than math.MaxInt64 overflow rand.Int63n, so those
+// are drawn from 64 random bits, rejecting values beyond the span.
+func fakeInt63Range(rnd *rand.Rand, min, max int64) int64 {
+	span := uint64(max) - uint64(min)
+	if span < 1<<63-1 {
+		return min + rnd.Int63n(int64(span)+1)
+	}
+	for {
+		// rand.Uint64 is not available before go1.8
+		if v := uint64(rnd.Int63())<<1 ^ uint64(rnd.Int63()); v <= span {
+			return int64(uint64(min) + v)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package sqlmock
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateFakeRows(t *testing.T) {
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	columns := []FakeColumnSpec{
+		{Name: "name", Kind: FakeName},
+		{Name: "email", Kind: FakeEmail},
+		{Name: "age", Kind: FakeIntRange, Min: 18, Max: 65},
+		{Name: "created_at", Kind: FakeTimeRange, From: from, To: to},
+	}
+
+	rows := GenerateFakeRows(columns, 20, 42)
+	if !reflect.DeepEqual(rows.cols, []string{"name", "email", "age", "created_at"}) {
+		t.Errorf("expected columns to be named by specs, but got %v", rows.cols)
+	}
+	if len(rows.rows) != 20 {
+		t.Fatalf("expected 20 rows to be generated, but got %d", len(rows.rows))
+	}
+
+	for i, row := range rows.rows {
+		if name := row[0].(string); len(strings.Fields(name)) != 2 {
+			t.Errorf("row #%d: expected a full name, but got %q", i, name)
+		}
+		if email := row[1].(string); !strings.HasSuffix(email, "@example.com") || email != strings.ToLower(email) {
+			t.Errorf("row #%d: expected an email, but got %q", i, email)
+		}
+		if age := row[2].(int64); age < 18 || age > 65 {
+			t.Errorf("row #%d: expected age between 18 and 65, but got %d", i, age)
+		}
+		if at := row[3].(time.Time); at.Before(from) || at.After(to) {
+			t.Errorf("row #%d: expected time between %s and %s, but got %s", i, from, to, at)
+		}
+	}
+
+	if again := GenerateFakeRows(columns, 20, 42); !reflect.DeepEqual(again.rows, rows.rows) {
+		t.Error("expected rows generated with the same seed to be equal")
+	}
+	if other := GenerateFakeRows(columns, 20, 7); reflect.DeepEqual(other.rows, rows.rows) {
+		t.Error("expected rows generated with another seed to differ")
+	}
+}
+
+func TestGenerateFakeRowsFullIntRange(t *testing.T) {
+	columns := []FakeColumnSpec{
+		{Name: "any", Kind: FakeIntRange, Min: math.MinInt64, Max: math.MaxInt64},
+		{Name: "wide", Kind: FakeIntRange, Min: -1, Max: math.MaxInt64},
+		{Name: "single", Kind: FakeIntRange, Min: math.MinInt64, Max: math.MinInt64},
+	}
+
+	var negative, positive bool
+	for i, row := range GenerateFakeRows(columns, 100, 42).rows {
+		if v := row[0].(int64); v < 0 {
+			negative = true
+		} else {
+			positive = true
+		}
+		if v := row[1].(int64); v < -1 {
+			t.Errorf("row #%d: expected value between -1 and %d, but got %d", i, int64(math.MaxInt64), v)
+		}
+		if v := row[2].(int64); v != math.MinInt64 {
+			t.Errorf("row #%d: expected value %d, but got %d", i, int64(math.MinInt64), v)
+		}
+	}
+	if !negative || !positive {
+		t.Error("expected values of the full int64 range to spread over both signs")
+	}
+}
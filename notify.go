@@ -0,0 +1,142 @@
+package sqlmock
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Notification is a simulated asynchronous notification, like
+// the one delivered by Postgres to connections which issued
+// a LISTEN command on its channel.
+type Notification struct {
+	Channel string
+	Payload string
+}
+
+// listener holds notifications delivered to a connection
+type listener struct {
+	channels map[string]bool
+	queue    []*Notification
+	ready    chan struct{}
+}
+
+// wait is a wait for notifications made on a connection,
+// it is kept apart from matched calls, since it is not
+// matched by an expectation
+type wait struct {
+	conn  int
+	calls int // number of calls matched before the wait
+}
+
+// waited records a wait for notifications on the connection
+func (c *conn) waited() {
+	c.mu.Lock()
+	c.waits = append(c.waits, wait{conn: c.id, calls: len(c.calls)})
+	c.mu.Unlock()
+}
+
+// listen updates channel subscriptions of the connection,
+// when query is a LISTEN or UNLISTEN command
+func (c *conn) listen(query string) {
+	kw, channel := listenCommand(query)
+	if kw == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l := c.listener()
+	switch {
+	case kw == "LISTEN":
+		l.channels[channel] = true
+	case channel == "*":
+		l.channels = make(map[string]bool)
+	default:
+		delete(l.channels, channel)
+	}
+}
+
+// listenCommand returns the keyword and the channel of
+// query, when it is a LISTEN or UNLISTEN command
+func listenCommand(query string) (kw, channel string) {
+	kw = leadingKeyword(query)
+	if kw != "LISTEN" && kw != "UNLISTEN" {
+		return "", ""
+	}
+	fields := strings.Fields(stripQuery(query))
+	if len(fields) < 2 {
+		return "", ""
+	}
+	return kw, strings.Trim(strings.TrimSuffix(fields[1], ";"), `"`)
+}
+
+// listener returns notifications state of the connection,
+// must be called while holding the mock lock
+func (c *conn) listener() *listener {
+	if c.listeners == nil {
+		c.listeners = make(map[*conn]*listener)
+	}
+	l, ok := c.listeners[c]
+	if !ok {
+		l = &listener{channels: make(map[string]bool), ready: make(chan struct{}, 1)}
+		c.listeners[c] = l
+	}
+	return l
+}
+
+// Notify delivers a notification to every connection,
+// which is listening on the channel
+func (c *sqlmock) Notify(channel, payload string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, l := range c.listeners {
+		if !l.channels[channel] {
+			continue
+		}
+		l.queue = append(l.queue, &Notification{Channel: channel, Payload: payload})
+		select {
+		case l.ready <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// AssertListenerPinned checks that notifications were waited for
+// and that every wait was made on a connection, which executed
+// a "LISTEN channel" exec before it, without "UNLISTEN" in between.
+func (c *sqlmock) AssertListenerPinned(channel string) error {
+	c.mu.Lock()
+	calls := make([]Call, len(c.calls))
+	copy(calls, c.calls)
+	waits := make([]wait, len(c.waits))
+	copy(waits, c.waits)
+	c.mu.Unlock()
+
+	if len(waits) == 0 {
+		return fmt.Errorf("expected notifications on channel '%s' to be waited for, but they were not", channel)
+	}
+	listening := make(map[int]bool)
+	var conns []int
+	var next int
+	for _, w := range waits {
+		// replay the calls matched before the wait
+		for ; next < w.calls; next++ {
+			call := calls[next]
+			if call.Kind != CallExec {
+				continue
+			}
+			kw, ch := listenCommand(call.Query)
+			switch {
+			case kw == "LISTEN" && ch == channel:
+				listening[call.Conn] = true
+				conns = append(conns, call.Conn)
+			case kw == "UNLISTEN" && (ch == channel || ch == "*"):
+				delete(listening, call.Conn)
+			}
+		}
+		if !listening[w.conn] {
+			return fmt.Errorf("notifications were waited for on connection %d, but channel '%s' was listened on connections %v", w.conn, channel, conns)
+		}
+	}
+	return nil
+}
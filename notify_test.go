@@ -0,0 +1,136 @@
+// +build go1.14
+
+package sqlmock
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestListenNotify(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("LISTEN orders").WillReturnResult(NewResult(0, 0))
+	mock.ExpectExec("UNLISTEN orders").WillReturnResult(NewResult(0, 0))
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while opening a connection", err)
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, "LISTEN orders"); err != nil {
+		t.Fatalf("error '%s' was not expected, while listening", err)
+	}
+
+	time.AfterFunc(10*time.Millisecond, func() {
+		mock.Notify("users", "ignored")
+		mock.Notify("orders", "created 42")
+	})
+
+	var n *Notification
+	err = conn.Raw(func(dc interface{}) (err error) {
+		n, err = dc.(NotificationWaiter).WaitForNotification(ctx)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while waiting for notification", err)
+	}
+	if n.Channel != "orders" || n.Payload != "created 42" {
+		t.Errorf("unexpected notification: %+v", n)
+	}
+
+	if _, err = conn.ExecContext(ctx, "UNLISTEN orders"); err != nil {
+		t.Fatalf("error '%s' was not expected, while unlistening", err)
+	}
+	mock.Notify("orders", "created 43")
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	err = conn.Raw(func(dc interface{}) error {
+		_, err := dc.(NotificationWaiter).WaitForNotification(timeout)
+		return err
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected wait to time out after unlisten, but got: %v", err)
+	}
+
+	if err := mock.AssertListenerPinned("orders"); err == nil {
+		t.Error("expected the wait after unlisten to be reported")
+	}
+	if n := len(mock.Calls()); n != 2 {
+		t.Errorf("expected notification waits not to be recorded as calls, but got %d calls", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestListenerPinned(t *testing.T) {
+	t.Parallel()
+	var matched []CallKind
+	db, mock, err := New(OnMatchOption(func(call Call) {
+		matched = append(matched, call.Kind)
+	}))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("LISTEN orders").WillReturnResult(NewResult(0, 0))
+	ctx := context.Background()
+	if err := mock.AssertListenerPinned("orders"); err == nil {
+		t.Error("expected an error, since notifications were not waited for")
+	}
+
+	listening, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while opening a connection", err)
+	}
+	defer listening.Close()
+	if _, err = listening.ExecContext(ctx, "LISTEN orders"); err != nil {
+		t.Fatalf("error '%s' was not expected, while listening", err)
+	}
+
+	wait := func(conn *sql.Conn) (id int) {
+		timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		conn.Raw(func(dc interface{}) error {
+			id = unwrapConn(dc.(driver.Conn)).id
+			_, err := dc.(NotificationWaiter).WaitForNotification(timeout)
+			return err
+		})
+		return id
+	}
+
+	wait(listening)
+	if err := mock.AssertListenerPinned("orders"); err != nil {
+		t.Errorf("expected the wait to be pinned to the listening connection, but got: %s", err)
+	}
+
+	other, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while opening a connection", err)
+	}
+	defer other.Close()
+	id := wait(other)
+
+	calls := mock.Calls()
+	expected := fmt.Sprintf("notifications were waited for on connection %d, but channel 'orders' was listened on connections [%d]", id, calls[0].Conn)
+	if err := mock.AssertListenerPinned("orders"); err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s', but got: %v", expected, err)
+	}
+	if len(matched) != 1 || matched[0] != CallExec {
+		t.Errorf("expected only the listen exec to be reported as matched, but got %v", matched)
+	}
+}
@@ -1,6 +1,12 @@
 package sqlmock
 
-import "database/sql/driver"
+import (
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
 
 // ValueConverterOption allows to create a sqlmock connection
 // with a custom ValueConverter to support drivers with special data types.
@@ -20,3 +26,212 @@ func QueryMatcherOption(queryMatcher QueryMatcher) func(*sqlmock) error {
 		return nil
 	}
 }
+
+// StrictPlaceholderArityOption makes sqlmock verify that the number
+// of bind placeholders in every executed query equals the number of
+// bound arguments. A mismatch is reported as a descriptive error at
+// match time, instead of relying on a cryptic database driver error.
+func StrictPlaceholderArityOption() func(*sqlmock) error {
+	return func(s *sqlmock) error {
+		s.strictPlaceholders = true
+		return nil
+	}
+}
+
+// RequireArgsForPlaceholdersOption makes sqlmock fail every exec or
+// query, which has bind placeholders, but was called without any
+// arguments. It catches code which accidentally drops the arguments
+// of a parameterized statement, while leaving the arity of calls with
+// arguments unchecked, unlike StrictPlaceholderArityOption.
+func RequireArgsForPlaceholdersOption() func(*sqlmock) error {
+	return func(s *sqlmock) error {
+		s.requireArgs = true
+		return nil
+	}
+}
+
+// StrictColumnOrderOption makes sqlmock verify that the columns
+// listed by every executed SELECT query are in the same order as
+// the columns of the rows mocked for it. This surfaces scan code
+// relying on column positions, which breaks once columns are added
+// or reordered. Queries selecting a star or expressions without an
+// alias are not verified.
+func StrictColumnOrderOption() func(*sqlmock) error {
+	return func(s *sqlmock) error {
+		s.strictColumnOrder = true
+		return nil
+	}
+}
+
+// RequireAllColumnsSelectedOption makes sqlmock verify that every
+// executed SELECT query names all the columns of the rows mocked for
+// it, in any order, so that a column added to the fixture, but not to
+// the query, fails with an error naming it. Queries selecting a star
+// or expressions without an alias are not verified. Note that it
+// compares the select list of the query with the columns of the rows,
+// rather than the Scan destinations, which the driver cannot see;
+// database/sql already fails a Scan with fewer destinations than
+// columns, with "expected N destination arguments".
+func RequireAllColumnsSelectedOption() func(*sqlmock) error {
+	return func(s *sqlmock) error {
+		s.allColumnsSelected = true
+		return nil
+	}
+}
+
+// CheckColumnCountOption makes sqlmock verify, when a query is
+// matched, that rows mocked for it declare as many columns as the
+// query selects, failing with an error naming both counts rather
+// than the generic "expected N destination arguments" of Scan.
+// Queries selecting a star are not verified.
+func CheckColumnCountOption() func(*sqlmock) error {
+	return func(s *sqlmock) error {
+		s.columnCount = true
+		return nil
+	}
+}
+
+// SkipNamedValueCheckOption makes the mock return driver.ErrSkip
+// from CheckNamedValue, like drivers which leave conversion of some
+// arguments to database/sql. Arguments are then converted by the
+// default database/sql conversion, instead of the ValueConverter of
+// the mock, and sql.Out arguments are rejected. Besides this check,
+// only calls of expectations with WillSkipFastPath return
+// driver.ErrSkip.
+func SkipNamedValueCheckOption() func(*sqlmock) error {
+	return func(s *sqlmock) error {
+		s.skipValueCheck = true
+		return nil
+	}
+}
+
+// ContextOnlyDriverOption makes sqlmock behave like a driver, which
+// implements only the context variants of driver interfaces. Its
+// connections implement driver.QueryerContext and driver.ExecerContext,
+// but not the legacy driver.Queryer and driver.Execer, so that code
+// can be tested against drivers of either capability. It has no
+// effect before Go 1.8, which has no context variants.
+func ContextOnlyDriverOption() func(*sqlmock) error {
+	return func(s *sqlmock) error {
+		s.contextOnly = true
+		return nil
+	}
+}
+
+// RejectNamedArgsOption makes sqlmock behave like a driver, which
+// does not support named parameters. Any database call with an
+// argument created by sql.Named fails with the same error, which
+// database/sql returns for such drivers.
+func RejectNamedArgsOption() func(*sqlmock) error {
+	return func(s *sqlmock) error {
+		s.rejectNamedArgs = true
+		return nil
+	}
+}
+
+// TableAllowlistOption makes sqlmock verify that every table referenced
+// by a matched query is in the given list, which catches typos and
+// references to dropped tables. Table names are compared case
+// insensitively and a schema qualified name like "public.users" is
+// allowed by "users" as well.
+//
+// Tables are found by a simple scan for names following FROM, JOIN,
+// UPDATE and INTO keywords. Comma separated FROM lists are not fully
+// parsed, and constructs like "EXTRACT(year FROM created_at)" may be
+// reported as table references. Use SkipTableAllowlist on expectations
+// of such complex queries.
+func TableAllowlistOption(tables []string) func(*sqlmock) error {
+	return func(s *sqlmock) error {
+		s.tableAllowlist = make(map[string]bool, len(tables))
+		for _, table := range tables {
+			s.tableAllowlist[strings.ToLower(table)] = true
+		}
+		return nil
+	}
+}
+
+// QuoteAgnosticMatchingOption makes the configured QueryMatcher ignore
+// vendor specific identifier quoting, so that a single expectation
+// matches `users` as used by MySQL, "users" as used by Postgres and
+// [users] as used by SQL Server. Backticks, double quotes and brackets
+// around identifiers are removed from the actual SQL before it is
+// matched, while string literals are left untouched. Expectations
+// should therefore be written without identifier quotes.
+func QuoteAgnosticMatchingOption() func(*sqlmock) error {
+	return func(s *sqlmock) error {
+		s.quoteAgnostic = true
+		return nil
+	}
+}
+
+// ErrTxAborted is the default error returned by statements executed
+// in a transaction aborted by a cancelled query, see AbortTxOnCancelOption.
+var ErrTxAborted = errors.New("current transaction is aborted, commands ignored until end of transaction block")
+
+// AbortTxOnCancelOption makes a cancelled query or exec leave its
+// transaction in an aborted state, like Postgres does. Any following
+// statement in such transaction fails with err, until the transaction
+// is rolled back, while Commit returns err without matching ExpectCommit.
+// If err is nil, ErrTxAborted is used.
+func AbortTxOnCancelOption(err error) func(*sqlmock) error {
+	return func(s *sqlmock) error {
+		if err == nil {
+			err = ErrTxAborted
+		}
+		s.abortTxErr = err
+		return nil
+	}
+}
+
+// IgnoreTrailingPredicateOption makes the configured QueryMatcher ignore
+// a predicate matching the given regular expression at the end of
+// actual SQL, like a tenant filter "AND tenant_id = ?" appended to
+// every query. Arguments bound to placeholders of such predicate are
+// assumed to be bound last. They are excluded from arguments matched
+// by WithArgs and may be asserted with WithTrailingArgs instead.
+func IgnoreTrailingPredicateOption(pattern string) func(*sqlmock) error {
+	return func(s *sqlmock) error {
+		re, err := regexp.Compile(`\s*(?:` + pattern + `)\s*$`)
+		if err != nil {
+			return err
+		}
+		s.trailingPredicate = re
+		return nil
+	}
+}
+
+// MaxDelayOption caps every delay set with WillDelayFor to at most d,
+// so that a misconfigured delay cannot hang the test suite. Delays
+// shorter than d are left untouched. If logf is not nil, it is called
+// every time a delay gets clamped.
+func MaxDelayOption(d time.Duration, logf func(format string, args ...interface{})) func(*sqlmock) error {
+	return func(s *sqlmock) error {
+		s.maxDelay = d
+		s.logDelay = logf
+		return nil
+	}
+}
+
+// OnMatchOption registers a hook called with every call matched by
+// a query or exec expectation, right after it was matched. The call
+// holds the raw argument values received by the driver, together with
+// ordinals of arguments marked with WithSensitiveArgs, so that tests
+// may compare them to what a logging middleware captured. The hook
+// is called once the matched expectation was unlocked, so it may use
+// getters like ExpectedQuery.RowsConsumed, but it must not set or
+// assert expectations of the mock.
+func OnMatchOption(fn func(call Call)) func(*sqlmock) error {
+	return func(s *sqlmock) error {
+		s.onMatch = fn
+		return nil
+	}
+}
+
+// TxTimelineOption makes the mock record its transaction
+// events under the given name to the shared timeline.
+func TxTimelineOption(timeline *TxTimeline, name string) func(*sqlmock) error {
+	return func(s *sqlmock) error {
+		s.timeline, s.timelineName = timeline, name
+		return nil
+	}
+}
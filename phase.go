@@ -0,0 +1,46 @@
+package sqlmock
+
+import "fmt"
+
+// phase is a named group of expectations, declared by ExpectPhase
+type phase struct {
+	name     string
+	expected []expectation
+}
+
+func (c *sqlmock) ExpectPhase(name string, expectations ...Expectation) {
+	p := &phase{name: name, expected: make([]expectation, len(expectations))}
+	for i, e := range expectations {
+		p.expected[i] = e
+	}
+	c.phases = append(c.phases, p)
+}
+
+// phaseReached checks whether every expectation of phases declared
+// before the phase of the matched expectation was already met. The
+// matched expectation must be locked by the caller.
+func (c *sqlmock) phaseReached(matched expectation) error {
+	current := -1
+	for i, p := range c.phases {
+		for _, e := range p.expected {
+			if e == matched {
+				current = i
+			}
+		}
+	}
+
+	for i := 0; i < current; i++ {
+		for _, e := range c.phases[i].expected {
+			if e == matched {
+				continue
+			}
+			e.Lock()
+			met := e.fulfilled()
+			e.Unlock()
+			if !met {
+				return fmt.Errorf("phase '%s' was entered before phase '%s' was complete, which still expects: %s", c.phases[current].name, c.phases[i].name, e)
+			}
+		}
+	}
+	return nil
+}
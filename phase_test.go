@@ -0,0 +1,48 @@
+package sqlmock
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpectPhase(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectPhase("users",
+		mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(NewRows([]string{"id"}).AddRow(1)),
+		mock.ExpectExec("UPDATE users").WillReturnResult(NewResult(0, 1)),
+	)
+	orders := []Expectation{
+		mock.ExpectExec("INSERT INTO orders").WillReturnResult(NewResult(1, 1)),
+	}
+	mock.ExpectPhase("orders", orders...)
+
+	if _, err = db.Exec("UPDATE users SET name = 'john'"); err != nil {
+		t.Fatalf("error '%s' was not expected, while updating users", err)
+	}
+
+	_, err = db.Exec("INSERT INTO orders (user_id) VALUES (1)")
+	if err == nil || !strings.Contains(err.Error(), "phase 'orders' was entered before phase 'users' was complete") {
+		t.Fatalf("expected orders phase not to be entered yet, but got: %v", err)
+	}
+
+	rows, err := db.Query("SELECT id FROM users")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while selecting users", err)
+	}
+	rows.Close()
+
+	if _, err = db.Exec("INSERT INTO orders (user_id) VALUES (1)"); err != nil {
+		t.Fatalf("error '%s' was not expected, while inserting an order", err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
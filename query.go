@@ -1,13 +1,43 @@
 package sqlmock
 
 import (
+	"bytes"
 	"fmt"
+	"net/url"
 	"regexp"
 	"strings"
 )
 
 var re = regexp.MustCompile("\\s+")
 
+var namedPlaceholder = regexp.MustCompile(`(^|[^:\w]):[A-Za-z_]\w*`)
+
+var (
+	tableRef    = regexp.MustCompile(`(?i)\b(FROM|JOIN|UPDATE|INTO)\s+([A-Za-z_][\w.]*)((\s+AS)?\s+([A-Za-z_]\w*))?`)
+	subquery    = regexp.MustCompile(`(?i)\(\s*SELECT\b`)
+	tableName   = regexp.MustCompile("(?i)\\b(FROM|JOIN|UPDATE|INTO)\\s+((?:[A-Za-z_]\\w*|\"[^\"]+\"|`[^`]+`)(?:\\.(?:[A-Za-z_]\\w*|\"[^\"]+\"|`[^`]+`))*)(\\s*\\()?")
+	leadingWord = regexp.MustCompile(`^(?:\s+|\(|--[^\n]*|/\*(?s:.*?)\*/)*([A-Za-z]+)`)
+	literal     = regexp.MustCompile(`'(?:[^']|'')*'|(^|[^\w$:@.])\d+(?:\.\d+)?\b`)
+	hintComment = regexp.MustCompile(`(?s)/\*\+(.*?)\*/`)
+	selectHead  = regexp.MustCompile(`(?i)^SELECT\s+((DISTINCT|ALL)\s+)?`)
+	columnName  = regexp.MustCompile("^(?:[A-Za-z_]\\w*\\.)*([A-Za-z_]\\w*|\"[^\"]+\"|`[^`]+`)$")
+	aliasName   = regexp.MustCompile("(?i)^.*[\\w)\"'`]\\s+(?:AS\\s+)?([A-Za-z_]\\w*|\"[^\"]+\"|`[^`]+`)$")
+	sqlComment  = regexp.MustCompile(`(?s)/\*.*?\*/|--[^\n]*`)
+	whereClause = regexp.MustCompile(`(?is)\bWHERE\s+(.*?)(?:\b(?:GROUP\s+BY|ORDER\s+BY|HAVING|LIMIT|OFFSET|RETURNING|FOR\s+UPDATE|UNION)\b|$)`)
+	conjunction = regexp.MustCompile(`(?i)\s+AND\s+`)
+	disjunction = regexp.MustCompile(`(?i)\bOR\b`)
+	sqlToken    = regexp.MustCompile(`'(?:[^']|'')*'|[A-Za-z_][\w.]*|\d+(?:\.\d+)?|[^\s\w]`)
+	tagComment  = regexp.MustCompile(`/\*((?:[^*]|\*+[^*/])*)\*+/\s*;?\s*$`)
+	commentTag  = regexp.MustCompile(`^\s*([^=\s]+)\s*=\s*'((?:[^'\\]|\\.)*)'\s*$`)
+	cteName     = regexp.MustCompile(`(?i)(\bWITH\s+(?:RECURSIVE\s+)?|\)\s*,\s*)([A-Za-z_]\w*)(\s*(?:\([^()]*\)\s*)?AS\s*(?:NOT\s+)?(?:MATERIALIZED\s+)?\()`)
+	strLiteral  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	qualifier   = regexp.MustCompile(`(^|[^\w.])([A-Za-z_]\w*)\.`)
+	wordToken   = regexp.MustCompile(`'(?:[^']|'')*'|\w+`)
+	indexedTerm = regexp.MustCompile(`(?i)^\(*\s*((?:[A-Za-z_]\w*\.)?([A-Za-z_]\w*))\s*(?:<=|>=|=|<(?:[^>=]|$)|>|\bIN\b|\bBETWEEN\b|\bIS\s+NULL\b)`)
+	quotedSpan  = regexp.MustCompile(`(?s)'(?:[^']|'')*'|/\*.*?\*/|--[^\n]*`)
+	sqlKeyword  = regexp.MustCompile(`(?i)^(WHERE|SET|VALUES|JOIN|INNER|LEFT|RIGHT|FULL|CROSS|OUTER|NATURAL|ON|USING|ORDER|GROUP|HAVING|LIMIT|OFFSET|UNION|EXCEPT|INTERSECT|FOR|RETURNING|WINDOW|DEFAULT|SELECT|END)$`)
+)
+
 // strip out new lines and trim spaces
 func stripQuery(q string) (s string) {
 	return strings.TrimSpace(re.ReplaceAllString(q, " "))
@@ -66,3 +96,562 @@ var QueryMatcherEqual QueryMatcher = QueryMatcherFunc(func(expectedSQL, actualSQ
 	}
 	return nil
 })
+
+// QueryMatcherNamedPlaceholderAgnostic is the SQL query matcher
+// which works like QueryMatcherEqual, but treats all named
+// placeholders like ":id" or ":p1" as equivalent, regardless
+// of their identifiers. Postgres style casts like "id::text"
+// and string literals like "':x'" are left untouched.
+var QueryMatcherNamedPlaceholderAgnostic QueryMatcher = normalizedMatcher(func(q string) string {
+	return outsideLiterals(q, func(part string) string {
+		return namedPlaceholder.ReplaceAllString(part, "${1}:?")
+	})
+})
+
+// QueryMatcherTableAliasAgnostic is the SQL query matcher
+// which works like QueryMatcherEqual, but resolves a simple
+// table alias to its base table before comparison, so that
+// "SELECT u.id FROM users u" matches "SELECT users.id FROM users".
+//
+// Only queries referencing a single table are resolved. Queries
+// with joins, subqueries or several tables are compared as they
+// are, like QueryMatcherEqual would do.
+var QueryMatcherTableAliasAgnostic QueryMatcher = normalizedMatcher(resolveTableAlias)
+
+// QueryMatcherSimilar builds an intentionally loose SQL query matcher,
+// which matches when the token overlap of expected and actual SQL is
+// at least threshold, between 0 and 1. The overlap is the Jaccard
+// similarity of the sets of tokens, as split by TokenizeSQL. It is
+// meant for smoke tests, which should tolerate minor changes of
+// generated SQL, since it may as well match SQL of different meaning.
+func QueryMatcherSimilar(threshold float64) QueryMatcher {
+	return QueryMatcherSimilarTokens(threshold, TokenizeSQL)
+}
+
+// QueryMatcherSimilarTokens works like QueryMatcherSimilar,
+// but splits both SQL strings to tokens with tokenize.
+func QueryMatcherSimilarTokens(threshold float64, tokenize func(sql string) []string) QueryMatcher {
+	return QueryMatcherFunc(func(expectedSQL, actualSQL string) error {
+		expect := stripQuery(expectedSQL)
+		actual := stripQuery(actualSQL)
+		if similarity := jaccard(tokenize(expect), tokenize(actual)); similarity < threshold {
+			return fmt.Errorf(`actual sql: "%s" is not similar to expected "%s", similarity %.2f is below %.2f`, actual, expect, similarity, threshold)
+		}
+		return nil
+	})
+}
+
+// TokenizeSQL splits SQL to lower case tokens: words, including
+// qualified names like "users.id", numbers, string literals and
+// single punctuation characters. It is the default tokenizer
+// of QueryMatcherSimilar.
+func TokenizeSQL(sql string) []string {
+	tokens := sqlToken.FindAllString(sql, -1)
+	for i, token := range tokens {
+		if !strings.HasPrefix(token, "'") {
+			tokens[i] = strings.ToLower(token)
+		}
+	}
+	return tokens
+}
+
+// jaccard returns the similarity of two sets of tokens,
+// as the size of their intersection divided by the
+// size of their union
+func jaccard(a, b []string) float64 {
+	set := make(map[string]int)
+	for _, token := range a {
+		set[token] |= 1
+	}
+	for _, token := range b {
+		set[token] |= 2
+	}
+	if len(set) == 0 {
+		return 1
+	}
+
+	var common int
+	for _, in := range set {
+		if in == 3 {
+			common++
+		}
+	}
+	return float64(common) / float64(len(set))
+}
+
+// resolveTableAlias replaces alias of the single table
+// referenced in query with the table name
+func resolveTableAlias(q string) string {
+	if subquery.MatchString(q) {
+		return q
+	}
+	refs := tableRef.FindAllStringSubmatchIndex(q, -1)
+	if len(refs) != 1 {
+		return q
+	}
+
+	m := refs[0]
+	if m[10] < 0 {
+		return q // not aliased
+	}
+	table, alias := q[m[4]:m[5]], q[m[10]:m[11]]
+	if sqlKeyword.MatchString(alias) {
+		return q
+	}
+
+	q = q[:m[6]] + q[m[7]:]
+	return outsideLiterals(q, func(part string) string {
+		var buf bytes.Buffer
+		var last int
+		for _, u := range qualifier.FindAllStringSubmatchIndex(part, -1) {
+			if part[u[4]:u[5]] != alias {
+				continue
+			}
+			buf.WriteString(part[last:u[4]])
+			buf.WriteString(table)
+			last = u[5]
+		}
+		buf.WriteString(part[last:])
+		return buf.String()
+	})
+}
+
+// outsideLiterals applies fn to the parts of query between
+// string literals, leaving the literals intact
+func outsideLiterals(q string, fn func(part string) string) string {
+	var buf bytes.Buffer
+	var last int
+	for _, lit := range strLiteral.FindAllStringIndex(q, -1) {
+		buf.WriteString(fn(q[last:lit[0]]))
+		buf.WriteString(q[lit[0]:lit[1]])
+		last = lit[1]
+	}
+	buf.WriteString(fn(q[last:]))
+	return buf.String()
+}
+
+// QueryMatcherCTENameAgnostic builds an SQL query matcher, which
+// works like QueryMatcherEqual, but treats names of common table
+// expressions generated by query builders, like "cte_1a2b", as
+// equivalent. Only CTE names defined by a WITH clause, which are
+// entirely matched by generated, are normalized, along with all
+// their references, while meaningful CTE names are left intact.
+// Names are normalized in order they are defined, so that queries
+// using several generated CTEs still match only in the same shape.
+func QueryMatcherCTENameAgnostic(generated *regexp.Regexp) QueryMatcher {
+	// anchored, so that a name must be matched entirely, even
+	// when a shorter alternative matches its prefix
+	whole := regexp.MustCompile(`^(?:` + generated.String() + `)$`)
+	return normalizedMatcher(func(q string) string {
+		return normalizeCTENames(q, whole)
+	})
+}
+
+// normalizeCTENames replaces names of CTEs which are matched
+// by generated with numbered placeholders, except in string
+// literals
+func normalizeCTENames(q string, generated *regexp.Regexp) string {
+	names := make(map[string]string)
+	for _, m := range cteName.FindAllStringSubmatch(q, -1) {
+		if _, ok := names[m[2]]; !ok && generated.MatchString(m[2]) {
+			names[m[2]] = fmt.Sprintf("<cte%d>", len(names)+1)
+		}
+	}
+	if len(names) == 0 {
+		return q
+	}
+	return wordToken.ReplaceAllStringFunc(q, func(token string) string {
+		if name, ok := names[token]; ok {
+			return name
+		}
+		return token
+	})
+}
+
+// normalizedMatcher builds a case sensitive equality matcher,
+// which applies normalize function on both expected and actual
+// SQL strings without whitespace before comparing them.
+func normalizedMatcher(normalize func(string) string) QueryMatcher {
+	return QueryMatcherFunc(func(expectedSQL, actualSQL string) error {
+		expect := normalize(stripQuery(expectedSQL))
+		actual := normalize(stripQuery(actualSQL))
+		if actual != expect {
+			return fmt.Errorf(`actual sql: "%s" does not equal to expected "%s"`, actual, expect)
+		}
+		return nil
+	})
+}
+
+// countPlaceholders counts bind placeholders in SQL query. String
+// literals, quoted identifiers and comments are skipped. Question
+// mark placeholders are counted per occurrence, while numbered "$1"
+// and named ":name" or "@name" placeholders are counted once per
+// distinct reference, since these may be repeated in a query.
+func countPlaceholders(query string) int {
+	var count int
+	refs := make(map[string]bool)
+	isWord := func(c byte) bool {
+		return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+	}
+	word := func(i int) int {
+		j := i
+		for j < len(query) && isWord(query[j]) {
+			j++
+		}
+		return j
+	}
+
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			// skip until closing quote, doubled quotes are escapes
+			for i++; i < len(query); i++ {
+				if query[i] == c {
+					if i+1 < len(query) && query[i+1] == c {
+						i++
+						continue
+					}
+					break
+				}
+			}
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(query)
+			}
+		case c == '?':
+			count++
+		case c == '$' && i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9':
+			j := word(i + 1)
+			refs[query[i:j]] = true
+			i = j - 1
+		case (c == ':' || c == '@') && i+1 < len(query) && isWord(query[i+1]) && query[i+1] != c:
+			if i > 0 && (query[i-1] == c || isWord(query[i-1])) {
+				continue // a cast like "id::text" or a variable like "@@version"
+			}
+			j := word(i + 1)
+			refs[string(c)+query[i+1:j]] = true
+			i = j - 1
+		}
+	}
+
+	return count + len(refs)
+}
+
+// selectColumns parses names of the columns selected by a simple
+// SELECT query, using column aliases where given. It reports false,
+// if the query is not a SELECT or any of selected columns is a star
+// or an expression without an alias.
+func selectColumns(query string) ([]string, bool) {
+	items, ok := selectItems(query)
+	if !ok {
+		return nil, false
+	}
+
+	columns := make([]string, len(items))
+	for i, item := range items {
+		var m []string
+		if m = columnName.FindStringSubmatch(item); m == nil {
+			if m = aliasName.FindStringSubmatch(item); m == nil || sqlKeyword.MatchString(m[1]) {
+				return nil, false
+			}
+		}
+		columns[i] = strings.Trim(m[1], "\"`")
+	}
+	return columns, true
+}
+
+// selectItems splits the select list of a SELECT query into
+// its trimmed items, either columns or expressions. It reports
+// false, if the query is not a SELECT.
+func selectItems(query string) ([]string, bool) {
+	query = stripQuery(query)
+	head := selectHead.FindString(query)
+	if head == "" {
+		return nil, false
+	}
+
+	isFrom := func(i int) bool {
+		end := i + len("FROM")
+		return query[i-1] == ' ' && end <= len(query) && strings.EqualFold(query[i:end], "FROM") &&
+			(end == len(query) || query[end] == ' ')
+	}
+
+	var items []string
+	var depth int
+	start := len(head)
+	for i := start; i <= len(query); i++ {
+		if i == len(query) {
+			items = append(items, query[start:i])
+			break
+		}
+		switch c := query[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			for i++; i < len(query) && query[i] != c; i++ {
+			}
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			items = append(items, query[start:i])
+			start = i + 1
+		case depth == 0 && isFrom(i):
+			items = append(items, query[start:i])
+			i = len(query)
+		}
+	}
+	for i, item := range items {
+		items[i] = strings.TrimSpace(item)
+	}
+	return items, true
+}
+
+// queryHints returns contents of optimizer hint
+// comments like "/*+ INDEX(users idx_name) */" in query
+func queryHints(query string) []string {
+	var hints []string
+	for _, m := range hintComment.FindAllStringSubmatch(query, -1) {
+		hints = append(hints, strings.TrimSpace(m[1]))
+	}
+	return hints
+}
+
+// queryComments returns all block and line comments in query
+func queryComments(query string) []string {
+	return sqlComment.FindAllString(query, -1)
+}
+
+// commentTags parses key value tags of the trailing comment of
+// query, in the sqlcommenter format "/*key='value',other='x'*/".
+// Keys and values are URL decoded and escaped quotes unescaped.
+func commentTags(query string) (map[string]string, error) {
+	m := tagComment.FindStringSubmatch(query)
+	if m == nil {
+		return nil, fmt.Errorf("has no trailing SQL comment")
+	}
+
+	tags := make(map[string]string)
+	for _, pair := range splitTags(m[1]) {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kv := commentTag.FindStringSubmatch(pair)
+		if kv == nil {
+			return nil, fmt.Errorf("has a malformed SQL comment tag '%s'", strings.TrimSpace(pair))
+		}
+		key, err := url.PathUnescape(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("has a malformed SQL comment tag key '%s': %s", kv[1], err)
+		}
+		value, err := url.PathUnescape(strings.Replace(kv[2], `\'`, "'", -1))
+		if err != nil {
+			return nil, fmt.Errorf("has a malformed SQL comment tag '%s' value: %s", key, err)
+		}
+		tags[key] = value
+	}
+	return tags, nil
+}
+
+// splitTags splits comment by commas, which are outside of quotes
+func splitTags(comment string) []string {
+	var tags []string
+	var quoted, escaped bool
+	start := 0
+	for i, r := range comment {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '\'':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			tags = append(tags, comment[start:i])
+			start = i + 1
+		}
+	}
+	return append(tags, comment[start:])
+}
+
+// indexFriendly uses a simple heuristic to check whether the WHERE
+// clause of query is able to use an index on the given leading
+// column: one of the top level AND terms must compare the column,
+// optionally qualified, by =, <, >, <=, >=, IN, BETWEEN or IS NULL.
+// The heuristic does not parse SQL, so a comparison nested in
+// parentheses or in a subquery counts as well, while a column
+// wrapped in a function or a cast does not. A WHERE clause with an
+// OR is rejected, because it may prevent the index from being used.
+// Inequality, like <> or !=, does not count as index friendly. String
+// literals are blanked out and comments removed, before the WHERE
+// clause is looked at.
+func indexFriendly(query, column string) error {
+	m := whereClause.FindStringSubmatch(quotedSpan.ReplaceAllStringFunc(query, func(span string) string {
+		if span[0] == '\'' {
+			return "''"
+		}
+		return " "
+	}))
+	if m == nil {
+		return fmt.Errorf("has no WHERE clause")
+	}
+	if disjunction.MatchString(m[1]) {
+		return fmt.Errorf("has an OR in WHERE clause, which may prevent the use of index on column '%s'", column)
+	}
+	for _, term := range conjunction.Split(m[1], -1) {
+		t := indexedTerm.FindStringSubmatch(strings.TrimSpace(term))
+		if t != nil && (strings.EqualFold(t[2], column) || strings.EqualFold(t[1], column)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("has no index friendly predicate on leading column '%s' in WHERE clause", column)
+}
+
+// referencedTables returns names of the tables referenced in query
+// after FROM, JOIN, UPDATE and INTO keywords, with quotes removed.
+// Table functions like "FROM unnest(...)" are skipped.
+func referencedTables(query string) []string {
+	var tables []string
+	for _, m := range tableName.FindAllStringSubmatch(query, -1) {
+		if m[3] != "" && !strings.EqualFold(m[1], "INTO") {
+			continue // a table function call
+		}
+		tables = append(tables, strings.NewReplacer("\"", "", "`", "").Replace(m[2]))
+	}
+	return tables
+}
+
+// leadingKeyword returns the upper cased first keyword of query,
+// skipping leading comments and parentheses
+func leadingKeyword(query string) string {
+	if m := leadingWord.FindStringSubmatch(query); m != nil {
+		return strings.ToUpper(m[1])
+	}
+	return ""
+}
+
+// statementKeyword returns the upper cased keyword of the query
+// statement, like leadingKeyword does, skipping the CTEs defined
+// by a leading WITH clause
+func statementKeyword(query string) string {
+	m := leadingWord.FindStringSubmatchIndex(query)
+	if m == nil {
+		return ""
+	}
+	if kw := strings.ToUpper(query[m[2]:m[3]]); kw != "WITH" {
+		return kw
+	}
+
+	var depth int
+	for i := m[1]; i < len(query); i++ {
+		switch query[i] {
+		case '\'':
+			// skip string literal, a doubled quote starts another
+			for i++; i < len(query) && query[i] != '\''; i++ {
+			}
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth > 0 {
+				continue
+			}
+			// a CTE definition or its column list was closed
+			rest := strings.TrimLeft(query[i+1:], " \t\r\n")
+			if strings.HasPrefix(rest, ",") {
+				continue
+			}
+			if w := leadingWord.FindStringSubmatch(rest); w != nil && !strings.EqualFold(w[1], "AS") {
+				return strings.ToUpper(w[1])
+			}
+		}
+	}
+	return "WITH"
+}
+
+// quoteAgnosticMatcher wraps matcher, so that identifier quotes
+// are removed from actual SQL before it is matched
+func quoteAgnosticMatcher(matcher QueryMatcher) QueryMatcher {
+	return QueryMatcherFunc(func(expectedSQL, actualSQL string) error {
+		return matcher.Match(expectedSQL, unquoteIdentifiers(actualSQL))
+	})
+}
+
+// compiledMatcher matches actual SQL against a compiled
+// regexp, in place of the expected SQL string
+type compiledMatcher struct {
+	re *regexp.Regexp
+}
+
+func (m compiledMatcher) Match(expectedSQL, actualSQL string) error {
+	if actual := stripQuery(actualSQL); !m.re.MatchString(actual) {
+		return fmt.Errorf(`could not match actual sql: "%s" with expected regexp "%s"`, actual, m.re.String())
+	}
+	return nil
+}
+
+// trailingPredicateMatcher wraps matcher, so that the
+// trailing predicate is removed from actual SQL before
+// it is matched
+func trailingPredicateMatcher(matcher QueryMatcher, predicate *regexp.Regexp) QueryMatcher {
+	return QueryMatcherFunc(func(expectedSQL, actualSQL string) error {
+		return matcher.Match(expectedSQL, predicate.ReplaceAllString(actualSQL, ""))
+	})
+}
+
+// unquoteIdentifiers removes backtick, double quote and bracket
+// identifier quotes from query, leaving string literals untouched
+func unquoteIdentifiers(query string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; c {
+		case '\'':
+			// copy string literal, doubled quotes are escapes
+			j := i + 1
+			for ; j < len(query); j++ {
+				if query[j] == c {
+					if j+1 < len(query) && query[j+1] == c {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			if j == len(query) {
+				j--
+			}
+			buf.WriteString(query[i : j+1])
+			i = j
+		case '`', '"':
+			// drop the quote
+		case '[':
+			if end := strings.IndexByte(query[i:], ']'); end > 1 && columnName.MatchString(query[i+1:i+end]) {
+				buf.WriteString(query[i+1 : i+end])
+				i += end
+				continue
+			}
+			buf.WriteByte(c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
+// fingerprint normalizes query by collapsing whitespace
+// and replacing string and number literals with "?"
+func fingerprint(query string) string {
+	return literal.ReplaceAllStringFunc(stripQuery(query), func(m string) string {
+		if m[0] == '\'' {
+			return "?"
+		}
+		if m[0] >= '0' && m[0] <= '9' {
+			return "?" // literal at the beginning of query
+		}
+		return m[:1] + "?"
+	})
+}
@@ -2,6 +2,8 @@ package sqlmock
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -121,3 +123,293 @@ func TestQueryMatcherEqual(t *testing.T) {
 		}
 	}
 }
+
+func TestQueryMatcherNamedPlaceholderAgnostic(t *testing.T) {
+	type testCase struct {
+		expected string
+		actual   string
+		err      error
+	}
+
+	cases := []testCase{
+		{"SELECT * FROM users WHERE id = :p1 AND status = :p2", "SELECT * FROM users WHERE id = :id AND status = :status", nil},
+		{"UPDATE users SET name = :name WHERE id = :id", "UPDATE users\n SET name = :p1\n WHERE id = :p2", nil},
+		{"SELECT id::text FROM users WHERE id = :id", "SELECT id::text FROM users WHERE id = :p1", nil},
+		{"SELECT id::text FROM users", "SELECT id::int FROM users", fmt.Errorf(`actual sql: "SELECT id::int FROM users" does not equal to expected "SELECT id::text FROM users"`)},
+		{"UPDATE t SET a = :a WHERE b = ':x'", "UPDATE t SET a = :p1 WHERE b = ':x'", nil},
+		{"UPDATE t SET a = :a WHERE b = ':x'", "UPDATE t SET a = :p1 WHERE b = ':y'", fmt.Errorf(`actual sql: "UPDATE t SET a = :? WHERE b = ':y'" does not equal to expected "UPDATE t SET a = :? WHERE b = ':x'"`)},
+		{"SELECT * FROM users WHERE id = :id", "SELECT * FROM orders WHERE id = :p1", fmt.Errorf(`actual sql: "SELECT * FROM orders WHERE id = :?" does not equal to expected "SELECT * FROM users WHERE id = :?"`)},
+	}
+
+	for i, c := range cases {
+		err := QueryMatcherNamedPlaceholderAgnostic.Match(c.expected, c.actual)
+		if err == nil && c.err != nil {
+			t.Errorf(`got no error, but expected "%v" at %d case`, c.err, i)
+			continue
+		}
+		if err != nil && c.err == nil {
+			t.Errorf(`got unexpected error "%v" at %d case`, err, i)
+			continue
+		}
+		if err == nil {
+			continue
+		}
+		if err.Error() != c.err.Error() {
+			t.Errorf(`expected error "%v", but got "%v" at %d case`, c.err, err, i)
+		}
+	}
+}
+
+func TestQueryMatcherTableAliasAgnostic(t *testing.T) {
+	type testCase struct {
+		expected string
+		actual   string
+		err      error
+	}
+
+	cases := []testCase{
+		{"SELECT users.id FROM users", "SELECT u.id FROM users u", nil},
+		{"SELECT u.id, u.name FROM users AS u WHERE u.id = ?", "SELECT users.id, users.name\n FROM users\n WHERE users.id = ?", nil},
+		{"UPDATE users SET name = ? WHERE users.id = ?", "UPDATE users u SET name = ? WHERE u.id = ?", nil},
+		{"SELECT id FROM users WHERE id = ?", "SELECT id FROM users WHERE id = ?", nil},
+		{"SELECT users.id FROM users WHERE users.name = 'u.x'", "SELECT u.id FROM users u WHERE u.name = 'u.x'", nil},
+		{"SELECT users.id FROM users WHERE users.name = 'users.x'", "SELECT u.id FROM users u WHERE u.name = 'u.x'", fmt.Errorf(`actual sql: "SELECT users.id FROM users WHERE users.name = 'u.x'" does not equal to expected "SELECT users.id FROM users WHERE users.name = 'users.x'"`)},
+		{"SELECT users.id FROM users", "SELECT o.id FROM orders o", fmt.Errorf(`actual sql: "SELECT orders.id FROM orders" does not equal to expected "SELECT users.id FROM users"`)},
+		{"SELECT users.id FROM users JOIN orders ON orders.user_id = users.id", "SELECT u.id FROM users u JOIN orders o ON o.user_id = u.id", fmt.Errorf(`actual sql: "SELECT u.id FROM users u JOIN orders o ON o.user_id = u.id" does not equal to expected "SELECT users.id FROM users JOIN orders ON orders.user_id = users.id"`)},
+	}
+
+	for i, c := range cases {
+		err := QueryMatcherTableAliasAgnostic.Match(c.expected, c.actual)
+		if err == nil && c.err != nil {
+			t.Errorf(`got no error, but expected "%v" at %d case`, c.err, i)
+			continue
+		}
+		if err != nil && c.err == nil {
+			t.Errorf(`got unexpected error "%v" at %d case`, err, i)
+			continue
+		}
+		if err == nil {
+			continue
+		}
+		if err.Error() != c.err.Error() {
+			t.Errorf(`expected error "%v", but got "%v" at %d case`, c.err, err, i)
+		}
+	}
+}
+
+func TestQueryMatcherCTENameAgnostic(t *testing.T) {
+	type testCase struct {
+		expected string
+		actual   string
+		err      error
+	}
+
+	cases := []testCase{
+		{"WITH cte_1a2b AS (SELECT id FROM users) SELECT id FROM cte_1a2b", "WITH cte_9f3e AS (SELECT id FROM users) SELECT id FROM cte_9f3e", nil},
+		{"WITH RECURSIVE cte_aa (id) AS (SELECT 1), cte_bb AS (SELECT id FROM cte_aa) SELECT * FROM cte_bb", "WITH RECURSIVE cte_01 (id) AS (SELECT 1), cte_02 AS (SELECT id FROM cte_01) SELECT * FROM cte_02", nil},
+		{"WITH active AS (SELECT id FROM users) SELECT id FROM active", "WITH active AS (SELECT id FROM users) SELECT id FROM active", nil},
+		{"WITH active AS (SELECT id FROM users) SELECT id FROM active", "WITH recent AS (SELECT id FROM users) SELECT id FROM recent", fmt.Errorf(`actual sql: "WITH recent AS (SELECT id FROM users) SELECT id FROM recent" does not equal to expected "WITH active AS (SELECT id FROM users) SELECT id FROM active"`)},
+		{"WITH cte_aa AS (SELECT 1) SELECT * FROM cte_aa WHERE note = 'cte_aa'", "WITH cte_01 AS (SELECT 1) SELECT * FROM cte_01 WHERE note = 'cte_01'", fmt.Errorf(`actual sql: "WITH <cte1> AS (SELECT 1) SELECT * FROM <cte1> WHERE note = 'cte_01'" does not equal to expected "WITH <cte1> AS (SELECT 1) SELECT * FROM <cte1> WHERE note = 'cte_aa'"`)},
+		{"WITH cte_aa AS (SELECT 1), cte_bb AS (SELECT 2) SELECT * FROM cte_aa", "WITH cte_01 AS (SELECT 1), cte_02 AS (SELECT 2) SELECT * FROM cte_02", fmt.Errorf(`actual sql: "WITH <cte1> AS (SELECT 1), <cte2> AS (SELECT 2) SELECT * FROM <cte2>" does not equal to expected "WITH <cte1> AS (SELECT 1), <cte2> AS (SELECT 2) SELECT * FROM <cte1>"`)},
+	}
+
+	matcher := QueryMatcherCTENameAgnostic(regexp.MustCompile(`cte_[0-9a-f]+`))
+	for i, c := range cases {
+		err := matcher.Match(c.expected, c.actual)
+		if err == nil && c.err != nil {
+			t.Errorf(`got no error, but expected "%v" at %d case`, c.err, i)
+			continue
+		}
+		if err != nil && c.err == nil {
+			t.Errorf(`got unexpected error "%v" at %d case`, err, i)
+			continue
+		}
+		if err == nil {
+			continue
+		}
+		if err.Error() != c.err.Error() {
+			t.Errorf(`expected error "%v", but got "%v" at %d case`, c.err, err, i)
+		}
+	}
+
+	// the shorter alternative matches a prefix only
+	matcher = QueryMatcherCTENameAgnostic(regexp.MustCompile(`cte|cte_\w+`))
+	if err := matcher.Match("WITH cte_1a2b AS (SELECT 1) SELECT * FROM cte_1a2b", "WITH cte_9f3e AS (SELECT 1) SELECT * FROM cte_9f3e"); err != nil {
+		t.Errorf("expected generated names to match an alternation entirely, but got: %s", err)
+	}
+}
+
+func TestStatementKeyword(t *testing.T) {
+	cases := map[string]string{
+		"/* audit */ update users SET name = ?":                                      "UPDATE",
+		"WITH stale AS (SELECT id FROM users WHERE seen < ?) DELETE FROM users":      "DELETE",
+		"WITH RECURSIVE t (n) AS (SELECT 1), u AS (SELECT ')' FROM t) INSERT INTO x": "INSERT",
+		"WITH recent AS (SELECT id FROM users) (SELECT id FROM recent)":              "SELECT",
+		"MERGE INTO users USING staged ON users.id = staged.id":                      "MERGE",
+		"WITH broken AS (SELECT 1":                                                   "WITH",
+	}
+	for query, expected := range cases {
+		if kw := statementKeyword(query); kw != expected {
+			t.Errorf("expected keyword %s of query '%s', but got %s", expected, query, kw)
+		}
+	}
+}
+
+func TestQueryMatcherSimilar(t *testing.T) {
+	cases := []struct {
+		threshold float64
+		expected  string
+		actual    string
+		matches   bool
+	}{
+		{0.9, "SELECT id, name FROM users WHERE id = ?", "select id, name\n from users where id = ?", true},
+		{0.8, "SELECT id, name FROM users WHERE id = ?", "SELECT id, name, email FROM users WHERE id = ?", true},
+		{0.95, "SELECT id, name FROM users WHERE id = ?", "SELECT id, name, email FROM users WHERE id = ?", false},
+		{0.5, "SELECT id, name FROM users WHERE id = ?", "DELETE FROM orders WHERE total > 0", false},
+		{0, "SELECT 1", "VACUUM", true},
+	}
+
+	for i, c := range cases {
+		err := QueryMatcherSimilar(c.threshold).Match(c.expected, c.actual)
+		if c.matches && err != nil {
+			t.Errorf("got unexpected error \"%v\" at %d case", err, i)
+		}
+		if !c.matches && err == nil {
+			t.Errorf("expected queries not to be similar enough at %d case", i)
+		}
+	}
+
+	expected := `actual sql: "SELECT 2" is not similar to expected "SELECT 1", similarity 0.33 is below 0.50`
+	if err := QueryMatcherSimilar(0.5).Match("SELECT 1", "SELECT 2"); err == nil || err.Error() != expected {
+		t.Errorf("expected error \"%s\", but got \"%v\"", expected, err)
+	}
+
+	words := QueryMatcherSimilarTokens(1, strings.Fields)
+	if err := words.Match("SELECT id FROM users", "SELECT id FROM  users"); err != nil {
+		t.Errorf("got unexpected error \"%v\" with a custom tokenizer", err)
+	}
+	if err := words.Match("SELECT id FROM users", "select id from users"); err == nil {
+		t.Error("expected a case sensitive custom tokenizer not to match")
+	}
+}
+
+func TestQueryPlaceholdersCount(t *testing.T) {
+	cases := map[string]int{
+		"SELECT * FROM users":                                        0,
+		"SELECT * FROM users WHERE id = ? AND status = ?":            2,
+		"SELECT * FROM users WHERE name = '?' AND id = ?":            1,
+		"SELECT * FROM users WHERE id = $1 OR parent_id = $1":        1,
+		"UPDATE users SET name = $2 WHERE id = $1":                   2,
+		"SELECT id::text FROM users WHERE id = :id AND x = :id":      1,
+		"SELECT @@version, @name -- is it ?\n FROM dual /* ? */":     1,
+		`SELECT "what?" FROM users WHERE note = 'it''s ?' AND a = ?`: 1,
+	}
+
+	for query, expected := range cases {
+		if n := countPlaceholders(query); n != expected {
+			t.Errorf("expected %d placeholders in query '%s', but got %d", expected, query, n)
+		}
+	}
+}
+
+func TestQuerySelectColumns(t *testing.T) {
+	cases := map[string]string{
+		"SELECT id, name FROM users":                                  "[id name]",
+		"select u.id, u.name as title\n from users u":                 "[id title]",
+		"SELECT DISTINCT `id`, \"full name\" FROM users":              "[id full name]",
+		"SELECT COUNT(*) AS total, max(id) m FROM users WHERE id > ?": "[total m]",
+		"SELECT 1 AS one":                            "[one]",
+		"SELECT * FROM users":                        "false",
+		"SELECT id, COUNT(*) FROM users GROUP BY id": "false",
+		"SELECT a + b FROM numbers":                  "false",
+		"UPDATE users SET name = ?":                  "false",
+	}
+
+	for query, expected := range cases {
+		columns, ok := selectColumns(query)
+		actual := fmt.Sprint(columns)
+		if !ok {
+			actual = "false"
+		}
+		if actual != expected {
+			t.Errorf("expected columns %s selected by query '%s', but got %s", expected, query, actual)
+		}
+	}
+}
+
+func TestQueryReferencedTables(t *testing.T) {
+	cases := map[string]string{
+		"SELECT id FROM users": "[users]",
+		"SELECT u.id FROM public.users u JOIN orders o ON o.uid = u.id": "[public.users orders]",
+		"INSERT INTO `audit_log`(id) VALUES (?)":                        "[audit_log]",
+		`UPDATE "Users" SET name = ?`:                                   "[Users]",
+		"DELETE FROM users WHERE id IN (SELECT uid FROM banned)":        "[users banned]",
+		"SELECT * FROM unnest(?) AS id":                                 "[]",
+	}
+
+	for query, expected := range cases {
+		if actual := fmt.Sprint(referencedTables(query)); actual != expected {
+			t.Errorf("expected tables %s referenced by query '%s', but got %s", expected, query, actual)
+		}
+	}
+}
+
+func TestQueryUnquoteIdentifiers(t *testing.T) {
+	cases := map[string]string{
+		"SELECT `id` FROM `users`":                              "SELECT id FROM users",
+		`SELECT "u"."id" FROM "public"."users" "u"`:             "SELECT u.id FROM public.users u",
+		"SELECT [id] FROM [dbo].[users]":                        "SELECT id FROM dbo.users",
+		"SELECT id FROM users WHERE name = '\"john\" [x] `y`'":  "SELECT id FROM users WHERE name = '\"john\" [x] `y`'",
+		"SELECT tags[1] FROM posts WHERE note = 'it''s \"ok\"'": "SELECT tags[1] FROM posts WHERE note = 'it''s \"ok\"'",
+	}
+
+	for query, expected := range cases {
+		if actual := unquoteIdentifiers(query); actual != expected {
+			t.Errorf("expected query '%s' to be unquoted as '%s', but got '%s'", query, expected, actual)
+		}
+	}
+}
+
+func TestQueryFingerprint(t *testing.T) {
+	cases := map[string]string{
+		"SELECT id FROM users WHERE id = 5":                       "SELECT id FROM users WHERE id = ?",
+		"SELECT id FROM users\n WHERE name = 'it''s' AND x > 1.5": "SELECT id FROM users WHERE name = ? AND x > ?",
+		"SELECT t1.id FROM t1 WHERE id = $1 AND y = -2 LIMIT 10":  "SELECT t1.id FROM t1 WHERE id = $1 AND y = -? LIMIT ?",
+		"SELECT id::int4 FROM users WHERE id IN (?, ?)":           "SELECT id::int4 FROM users WHERE id IN (?, ?)",
+	}
+
+	for query, expected := range cases {
+		if actual := fingerprint(query); actual != expected {
+			t.Errorf("expected fingerprint '%s' of query '%s', but got '%s'", expected, query, actual)
+		}
+	}
+}
+
+func TestQueryIndexFriendly(t *testing.T) {
+	cases := map[string]string{
+		"SELECT id FROM users WHERE email = ?":                                "",
+		"SELECT id FROM users u WHERE u.active AND u.email IN (?, ?) LIMIT 1": "",
+		"SELECT id FROM users WHERE (email >= ? AND name = ?) ORDER BY email": "",
+		"UPDATE users SET name = ? WHERE email IS NULL":                       "",
+		"SELECT id FROM users /* where email = ? */":                          "has no WHERE clause",
+		"SELECT id FROM users WHERE lower(email) = ?":                         "has no index friendly predicate on leading column 'email' in WHERE clause",
+		"SELECT id FROM users WHERE name = ? ORDER BY email":                  "has no index friendly predicate on leading column 'email' in WHERE clause",
+		"SELECT id FROM users WHERE email = ? OR name = ?":                    "has an OR in WHERE clause, which may prevent the use of index on column 'email'",
+		"SELECT id FROM users WHERE EMAIL = ?":                                "",
+		"SELECT id FROM users WHERE email <> ?":                               "has no index friendly predicate on leading column 'email' in WHERE clause",
+		"SELECT id FROM users WHERE email != ?":                               "has no index friendly predicate on leading column 'email' in WHERE clause",
+		"SELECT id FROM users WHERE email < ? AND name = 'A OR B'":            "",
+		"SELECT id FROM users WHERE name = 'email = x' AND id = ?":            "has no index friendly predicate on leading column 'email' in WHERE clause",
+		"SELECT id FROM users WHERE email_verified = ?":                       "has no index friendly predicate on leading column 'email' in WHERE clause",
+	}
+
+	for query, expected := range cases {
+		actual := ""
+		if err := indexFriendly(query, "email"); err != nil {
+			actual = err.Error()
+		}
+		if actual != expected {
+			t.Errorf("expected query '%s' to be checked with error '%s', but got '%s'", query, expected, actual)
+		}
+	}
+}
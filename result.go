@@ -37,3 +37,34 @@ func (r *result) LastInsertId() (int64, error) {
 func (r *result) RowsAffected() (int64, error) {
 	return r.rowsAffected, r.err
 }
+
+// insertIDResult overrides the last insert id of
+// a result with the one computed for the exec
+type insertIDResult struct {
+	driver.Result
+	insertID int64
+}
+
+func (r *insertIDResult) LastInsertId() (int64, error) {
+	_, err := r.Result.LastInsertId()
+	return r.insertID, err
+}
+
+// WarningsResult is implemented by driver results of execs,
+// which were set to produce warnings by WillReturnWarnings.
+type WarningsResult interface {
+	driver.Result
+
+	// Warnings returns the informational rows produced
+	// by the exec.
+	Warnings() driver.Rows
+}
+
+type warningsResult struct {
+	driver.Result
+	warnings driver.Rows
+}
+
+func (r *warningsResult) Warnings() driver.Rows {
+	return r.warnings
+}
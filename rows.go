@@ -4,9 +4,13 @@ import (
 	"bytes"
 	"database/sql/driver"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
+	"reflect"
+	"sort"
 	"strings"
+	"time"
 )
 
 const invalidate = "☠☠☠ MEMORY OVERWRITTEN ☠☠☠ "
@@ -23,10 +27,14 @@ var CSVColumnParser = func(s string) []byte {
 }
 
 type rowSets struct {
-	sets []*Rows
-	pos  int
-	ex   *ExpectedQuery
-	raw  [][]byte
+	sets    []*Rows
+	pos     int
+	row     int // position of the row cursor in the current set
+	ex      *ExpectedQuery
+	raw     [][]byte
+	drained bool
+	done    <-chan struct{} // closed when query context is done
+	closing <-chan struct{} // closed when the database is closed
 }
 
 func (rs *rowSets) Columns() []string {
@@ -35,20 +43,70 @@ func (rs *rowSets) Columns() []string {
 
 func (rs *rowSets) Close() error {
 	rs.invalidateRaw()
+	rs.ex.Lock()
 	rs.ex.rowsWereClosed = true
+	if !rs.drained {
+		rs.ex.rowsNotDrained = true
+	}
+	rs.ex.Unlock()
 	return rs.sets[rs.pos].closeErr
 }
 
 // advances to next row
 func (rs *rowSets) Next(dest []driver.Value) error {
+	rs.ex.Lock()
+	rs.ex.nextTimings = append(rs.ex.nextTimings, time.Now())
+	rs.ex.Unlock()
+
 	r := rs.sets[rs.pos]
-	r.pos++
+	rs.row++
 	rs.invalidateRaw()
-	if r.pos > len(r.rows) {
+	if rs.row == 1 && r.firstRowDelay > 0 {
+		if err := rs.wait(r.firstRowDelay); err != nil {
+			return err
+		}
+	}
+	if rs.row > len(r.rows) {
+		if rs.pos == len(rs.sets)-1 {
+			rs.drained = true
+		}
+		if r.deferErr != nil {
+			return r.deferErr
+		}
 		return io.EOF // per interface spec
 	}
 
-	for i, col := range r.rows[r.pos-1] {
+	if rs.row > 1 && rs.ex.singleRow {
+		rs.ex.Lock()
+		rs.ex.manyRowsRead = true
+		rs.ex.Unlock()
+		return fmt.Errorf("query was expected to return a single row, but more rows were read")
+	}
+
+	if r.batchSize > 0 && (rs.row-1)%r.batchSize == 0 {
+		if rs.row > 1 && r.batchDelay > 0 {
+			if err := rs.wait(r.batchDelay); err != nil {
+				return err
+			}
+		}
+		rs.ex.Lock()
+		rs.ex.batchesFetched++
+		rs.ex.Unlock()
+	}
+
+	if r.nextDelay != nil {
+		if err := rs.wait(r.nextDelay(rs.row - 1)); err != nil {
+			return err
+		}
+	}
+
+	for i, col := range r.rows[rs.row-1] {
+		if fn, ok := col.(func() driver.Value); ok {
+			var err error
+			if col, err = r.converter.ConvertValue(fn()); err != nil {
+				return fmt.Errorf("row #%d, column #%d (%q) lazy value: %s", rs.row, i, r.cols[i], err)
+			}
+		}
 		if b, ok := rawBytes(col); ok {
 			rs.raw = append(rs.raw, b)
 			dest[i] = b
@@ -57,7 +115,14 @@ func (rs *rowSets) Next(dest []driver.Value) error {
 		dest[i] = col
 	}
 
-	return r.nextErr[r.pos-1]
+	err := r.nextErr[rs.row-1]
+	if err == nil {
+		// database/sql stops at a row error, which row is never scanned
+		rs.ex.Lock()
+		rs.ex.rowsConsumed++
+		rs.ex.Unlock()
+	}
+	return err
 }
 
 // transforms to debuggable printable string
@@ -120,16 +185,42 @@ func (rs *rowSets) invalidateRaw() {
 type Rows struct {
 	converter driver.ValueConverter
 	cols      []string
+	def       []*Column
 	rows      [][]driver.Value
-	pos       int
 	nextErr   map[int]error
 	closeErr  error
+	colsErr   error
+	deferErr  error
+	nextDelay func(rowIndex int) time.Duration
+
+	firstRowDelay time.Duration
+	batchSize     int
+	batchDelay    time.Duration
+
+	windowCount bool // last column holds windowTotal, see WithWindowCount
+	windowTotal int64
 }
 
 // NewRows allows Rows to be created from a
 // sql driver.Value slice or from the CSV string and
 // to be used as sql driver.Rows.
 // Use Sqlmock.NewRows instead if using a custom converter
+//
+// Columns may be empty in order to simulate a query returning
+// no columns, like DDL executed through Query. Such rows report
+// zero columns and reach EOF on the first Next call.
+//
+// Column names may repeat, like for a join selecting columns of
+// the same name from several tables. Such columns are reported by
+// rows.Columns as they were given, in order, and values are read
+// by their position, the same way a database returns them. Code
+// collecting values by column name sees only one of them, while
+// FilterByColumn uses the first column of that name. Column checks
+// compare names case insensitively: StrictColumnOrderOption compares
+// them position by position, so that a repeated name must be selected
+// at each of its positions, while RequireAllColumnsSelectedOption
+// requires every name to be selected at least once and
+// CheckColumnCountOption counts every position.
 func NewRows(columns []string) *Rows {
 	return &Rows{
 		cols:      columns,
@@ -138,6 +229,86 @@ func NewRows(columns []string) *Rows {
 	}
 }
 
+// NewRowsWithColumnDefinition allows Rows to be created from
+// column definitions, which describe column metadata, like the
+// column length, in addition to the column names.
+// Use Sqlmock.NewRowsWithColumnDefinition instead if using a
+// custom converter
+func NewRowsWithColumnDefinition(columns ...*Column) *Rows {
+	cols := make([]string, len(columns))
+	for i, col := range columns {
+		cols[i] = col.Name()
+	}
+	rows := NewRows(cols)
+	rows.def = columns
+	return rows
+}
+
+// RowsFromMap allows two column Rows to be created from a map,
+// with a row of key and value per map entry. Since map iteration
+// order is random, rows are sorted by key, so that fixtures are
+// deterministic. Keys of string, integer and float kinds are sorted
+// naturally, other keys by their formatted value. It panics if m
+// is not a map.
+func RowsFromMap(keyCol, valCol string, m interface{}) *Rows {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Map {
+		panic(fmt.Sprintf("expected a map, but got %T", m))
+	}
+
+	keys := v.MapKeys()
+	sort.Sort(mapKeys(keys))
+
+	rows := NewRows([]string{keyCol, valCol})
+	for _, key := range keys {
+		rows.AddRow(key.Interface(), v.MapIndex(key).Interface())
+	}
+	return rows
+}
+
+// JSONAggColumn allows single column Rows to be created, with one
+// row holding the JSON array of children, as aggregated by json_agg
+// of PostgreSQL, which is used by ORMs to eager load child rows. Keys
+// of every child are serialized in sorted order, so that the cell is
+// deterministic. Like json_agg over no rows, the cell is NULL if there
+// are no children. It panics if a child cannot be serialized.
+func JSONAggColumn(colName string, children []map[string]interface{}) *Rows {
+	rows := NewRows([]string{colName})
+	if len(children) == 0 {
+		return rows.AddRow(nil)
+	}
+
+	b, err := json.Marshal(children)
+	if err != nil {
+		panic(fmt.Sprintf("column %q: %s", colName, err))
+	}
+	return rows.AddRow(string(b))
+}
+
+// mapKeys sorts map keys with lessKey
+type mapKeys []reflect.Value
+
+func (k mapKeys) Len() int           { return len(k) }
+func (k mapKeys) Swap(i, j int)      { k[i], k[j] = k[j], k[i] }
+func (k mapKeys) Less(i, j int) bool { return lessKey(k[i], k[j]) }
+
+// lessKey orders map keys of the same type
+func lessKey(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.String:
+		return a.String() < b.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	}
+	return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+}
+
 // CloseError allows to set an error
 // which will be returned by rows.Close
 // function.
@@ -150,6 +321,18 @@ func (r *Rows) CloseError(err error) *Rows {
 	return r
 }
 
+// ColumnsError allows to set an error, which is returned
+// when the driver describes result columns of the rows,
+// before any row is read. Unlike RowError, which fails
+// rows.Next for a given row, and CloseError, the error
+// fails the query itself, while the query expectation is
+// still triggered. For the second and later result sets,
+// the error is returned by rows.NextResultSet.
+func (r *Rows) ColumnsError(err error) *Rows {
+	r.colsErr = err
+	return r
+}
+
 // RowError allows to set an error
 // which will be returned when a given
 // row number is read
@@ -158,17 +341,87 @@ func (r *Rows) RowError(row int, err error) *Rows {
 	return r
 }
 
+// DeferredError allows to set an error, which is returned
+// after all rows were read, instead of the end of rows. So
+// rows.Next returns false as usual, once all rows were scanned,
+// but rows.Err returns the error, like for drivers which report
+// a failure only after the result was streamed.
+func (r *Rows) DeferredError(err error) *Rows {
+	r.deferErr = err
+	return r
+}
+
+// FirstRowDelay allows to delay the first rows.Next call for
+// the given duration, while the following rows are returned
+// instantly, unless NextDelayFunc is set. This models a query
+// which latency is dominated by its execution, before rows are
+// streamed. When rows are returned by a query with context, the
+// delay is interrupted once the context is done, or the database
+// is closed.
+func (r *Rows) FirstRowDelay(d time.Duration) *Rows {
+	r.firstRowDelay = d
+	return r
+}
+
+// NextDelayFunc allows to delay every row read by rows.Next
+// for a duration returned by the given func for the zero based
+// index of the row being read. This makes it possible to model
+// bursty cursors, for example fast rows followed by a stall.
+// When rows are returned by a query with context, the delay is
+// interrupted once the context is done, or the database is closed.
+func (r *Rows) NextDelayFunc(fn func(rowIndex int) time.Duration) *Rows {
+	r.nextDelay = fn
+	return r
+}
+
+// BatchSize allows to deliver rows in batches of n rows, like
+// a cursor reading with a fetch size. A batch is fetched when its
+// first row is read, which is counted by ExpectedQuery.BatchesFetched,
+// so that a test may assert a chunked consumer read every batch.
+func (r *Rows) BatchSize(n int) *Rows {
+	r.batchSize = n
+	return r
+}
+
+// BatchDelay allows to delay fetching of every batch set by
+// BatchSize, except the first one, for the given duration, so
+// that batch boundaries are observable by the consumer timing.
+// When rows are returned by a query with context, the delay is
+// interrupted once the context is done, or the database is closed.
+func (r *Rows) BatchDelay(d time.Duration) *Rows {
+	r.batchDelay = d
+	return r
+}
+
 // AddRow composed from database driver.Value slice
 // return the same instance to perform subsequent actions.
 // Note that the number of values must match the number
 // of columns
+//
+// A value may be given as func() driver.Value in order to
+// compute it lazily, only when the row is read by rows.Next.
+// Lazy values of a row are evaluated in column order and each
+// func is called at most once per row read. Since the driver
+// fills all columns of a row at once, values are computed for
+// every row read, regardless of which columns are scanned.
+//
+// Once WithWindowCount was called, the total count column may be
+// omitted from values, since it is filled with the total.
 func (r *Rows) AddRow(values ...driver.Value) *Rows {
+	if r.windowCount && len(values) == len(r.cols)-1 {
+		values = append(values[:len(values):len(values)], r.windowTotal)
+	}
 	if len(values) != len(r.cols) {
 		panic("Expected number of values to match number of columns")
 	}
 
 	row := make([]driver.Value, len(r.cols))
 	for i, v := range values {
+		if _, ok := v.(func() driver.Value); ok {
+			row[i] = v // evaluated on read
+			continue
+		}
+
 		// Convert user-friendly values (such as int or driver.Valuer)
 		// to database/sql native value (driver.Value such as int64)
 		var err error
@@ -187,6 +440,117 @@ func (r *Rows) AddRow(values ...driver.Value) *Rows {
 	return r
 }
 
+// WithWindowCount adds a column holding the total count to every
+// row, like "COUNT(*) OVER() AS colName" selected by a paginated
+// query does, which reports the same total on each row. Rows added
+// afterwards, by AddRow or FromCSVString, get the total filled in
+// too, unless a value is given for the column. It should be called
+// once per rows.
+func (r *Rows) WithWindowCount(colName string, total int64) *Rows {
+	r.cols = append(r.cols[:len(r.cols):len(r.cols)], colName)
+	if r.def != nil {
+		r.def = append(r.def[:len(r.def):len(r.def)], NewColumn(colName))
+	}
+	for i, row := range r.rows {
+		r.rows[i] = append(row[:len(row):len(row)], total)
+	}
+	r.windowCount, r.windowTotal = true, total
+	return r
+}
+
+// Filter returns a copy of the rows, which contains only the
+// rows for which keep returns true, in their original order.
+// Errors set with RowError follow the row they were set for.
+// Together with WillReturnRowsFunc it allows to return a subset
+// of a fixture, depending on the arguments of the query. Lazy values
+// are given to keep as their funcs, without being evaluated.
+func (r *Rows) Filter(keep func(row []driver.Value) bool) *Rows {
+	var idx []int
+	for i, row := range r.rows {
+		if keep(row) {
+			idx = append(idx, i)
+		}
+	}
+	return r.pick(idx)
+}
+
+// FilterByColumn returns a copy of the rows, which contains only
+// the rows having the given value in column col, in their original
+// order. The value is converted the same way as values given to
+// AddRow. Together with WillReturnRowsFunc it allows to model row
+// level security, like returning only rows where the owner column
+// equals the current user argument. If several columns have the
+// given name, the first one is used. Lazy values of the column are
+// evaluated and converted to be compared, in addition to when their
+// rows are read. It panics if there is no such column, or the value
+// cannot be converted.
+func (r *Rows) FilterByColumn(col string, value driver.Value) *Rows {
+	idx := -1
+	for i, name := range r.cols {
+		if name == col {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		panic(fmt.Sprintf("rows have no column %q to filter by", col))
+	}
+
+	v, err := r.converter.ConvertValue(value)
+	if err != nil {
+		panic(fmt.Errorf("column %q type %T: %s", col, value, err))
+	}
+	return r.Filter(func(row []driver.Value) bool {
+		cell := row[idx]
+		if fn, ok := cell.(func() driver.Value); ok {
+			if cell, err = r.converter.ConvertValue(fn()); err != nil {
+				panic(fmt.Errorf("column %q lazy value: %s", col, err))
+			}
+		}
+		return reflect.DeepEqual(cell, v)
+	})
+}
+
+// Sort returns a copy of the rows, ordered by the given less
+// func. The sort is stable, so that rows which are equal keep
+// their original order. Errors set with RowError follow the row
+// they were set for. Lazy values are given to less as their funcs,
+// without being evaluated.
+func (r *Rows) Sort(less func(a, b []driver.Value) bool) *Rows {
+	idx := make([]int, len(r.rows))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Stable(rowOrder{idx: idx, rows: r.rows, less: less})
+	return r.pick(idx)
+}
+
+// rowOrder sorts row indexes by the less func given to Sort
+type rowOrder struct {
+	idx  []int
+	rows [][]driver.Value
+	less func(a, b []driver.Value) bool
+}
+
+func (o rowOrder) Len() int           { return len(o.idx) }
+func (o rowOrder) Swap(i, j int)      { o.idx[i], o.idx[j] = o.idx[j], o.idx[i] }
+func (o rowOrder) Less(i, j int) bool { return o.less(o.rows[o.idx[i]], o.rows[o.idx[j]]) }
+
+// pick copies the rows at the given indexes, carrying
+// over row errors and all the other settings.
+func (r *Rows) pick(idx []int) *Rows {
+	cp := *r
+	cp.rows = make([][]driver.Value, len(idx))
+	cp.nextErr = make(map[int]error)
+	for i, j := range idx {
+		cp.rows[i] = r.rows[j]
+		if err, ok := r.nextErr[j]; ok {
+			cp.nextErr[i] = err
+		}
+	}
+	return &cp
+}
+
 // FromCSVString build rows from csv string.
 // return the same instance to perform subsequent actions.
 // Note that the number of values must match the number
@@ -205,7 +569,65 @@ func (r *Rows) FromCSVString(s string) *Rows {
 		for i, v := range res {
 			row[i] = CSVColumnParser(strings.TrimSpace(v))
 		}
+		if r.windowCount && len(res) == len(r.cols)-1 {
+			row[len(res)] = r.windowTotal
+		}
 		r.rows = append(r.rows, row)
 	}
 	return r
 }
+
+// ValidateScannable dry-runs scanning of every row value into a
+// destination of the Go type given for its column, the same way
+// database/sql would do for the code under test. It returns an
+// error for each cell, which cannot be scanned, so that fixtures
+// with mismatching types are caught before the test runs. A nil
+// type skips validation of the column. Note that lazy values are
+// evaluated, and a lazy value which cannot be converted is reported
+// too, while row errors and delays are ignored.
+func (r *Rows) ValidateScannable(destTypes []reflect.Type) []error {
+	if len(destTypes) != len(r.cols) {
+		return []error{fmt.Errorf("expected %d destination types, one for each column, but got %d", len(r.cols), len(destTypes))}
+	}
+
+	db, mock, err := New()
+	if err != nil {
+		return []error{err}
+	}
+	defer db.Close()
+
+	// only the values are scanned, without errors and delays
+	rows := &Rows{
+		cols:      r.cols,
+		rows:      r.rows,
+		nextErr:   make(map[int]error),
+		converter: r.converter,
+	}
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+	rs, err := db.Query("SELECT")
+	if err != nil {
+		return []error{err}
+	}
+	defer rs.Close()
+
+	var errs []error
+	for row := 1; rs.Next(); row++ {
+		for col, typ := range destTypes {
+			if typ == nil {
+				continue
+			}
+			dest := make([]interface{}, len(destTypes))
+			for i := range dest {
+				dest[i] = new(interface{})
+			}
+			dest[col] = reflect.New(typ).Interface()
+			if err := rs.Scan(dest...); err != nil {
+				errs = append(errs, fmt.Errorf("row #%d: %s", row, err))
+			}
+		}
+	}
+	if err := rs.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
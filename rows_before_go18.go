@@ -0,0 +1,11 @@
+// +build !go1.8
+
+package sqlmock
+
+import "time"
+
+// wait delays row read
+func (rs *rowSets) wait(d time.Duration) error {
+	time.Sleep(d)
+	return nil
+}
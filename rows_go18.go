@@ -2,7 +2,10 @@
 
 package sqlmock
 
-import "io"
+import (
+	"io"
+	"time"
+)
 
 // Implement the "RowsNextResultSet" interface
 func (rs *rowSets) HasNextResultSet() bool {
@@ -16,5 +19,28 @@ func (rs *rowSets) NextResultSet() error {
 	}
 
 	rs.pos++
-	return nil
+	rs.row = 0
+	return rs.sets[rs.pos].colsErr
+}
+
+// wait delays row read, unless query context gets
+// done or the database is closed
+func (rs *rowSets) wait(d time.Duration) error {
+	select {
+	case <-time.After(d):
+		return nil
+	case <-rs.done:
+		return ErrCancelled
+	case <-rs.closing:
+		return ErrCancelled
+	}
+}
+
+// Implement the "RowsColumnTypeLength" interface
+func (rs *rowSets) ColumnTypeLength(index int) (int64, bool) {
+	def := rs.sets[rs.pos].def
+	if index >= len(def) {
+		return 0, false
+	}
+	return def[index].Length()
 }
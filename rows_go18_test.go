@@ -3,10 +3,13 @@
 package sqlmock
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestQueryMultiRows(t *testing.T) {
@@ -93,6 +96,256 @@ func TestQueryMultiRows(t *testing.T) {
 	}
 }
 
+func TestQueryMultiRowsWithDifferentColumns(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ids := NewRows([]string{"id"}).AddRow(1).AddRow(2)
+	users := NewRows([]string{"name", "email"}).AddRow("john", "john@example.com")
+	mock.ExpectQuery("CALL user_report").WillReturnRows(ids, users)
+
+	rows, err := db.Query("CALL user_report()")
+	if err != nil {
+		t.Fatalf("error was not expected, but got: %v", err)
+	}
+	defer rows.Close()
+
+	if cols, _ := rows.Columns(); fmt.Sprint(cols) != "[id]" {
+		t.Errorf("expected columns of the first result set, but got %v", cols)
+	}
+	var sum int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Fatalf("error was not expected, but got: %v", err)
+		}
+		sum += id
+	}
+	if sum != 3 {
+		t.Errorf("expected ids to sum up to 3, but got %d", sum)
+	}
+
+	if !rows.NextResultSet() {
+		t.Fatal("had to have next result set")
+	}
+	if cols, _ := rows.Columns(); fmt.Sprint(cols) != "[name email]" {
+		t.Errorf("expected columns of the second result set, but got %v", cols)
+	}
+	if !rows.Next() {
+		t.Fatal("expected a row to be available in second result set")
+	}
+	var name, email string
+	if err := rows.Scan(&name, &email); err != nil {
+		t.Fatalf("error was not expected, but got: %v", err)
+	}
+	if name != "john" || email != "john@example.com" {
+		t.Errorf("unexpected row values name: %v email: %v", name, email)
+	}
+
+	if rows.NextResultSet() {
+		t.Error("was not expecting another result set")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+func TestQueryMultiRowsColumnsError(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	errDescribe := fmt.Errorf("could not describe result columns")
+	mock.ExpectQuery("CALL user_report").
+		WillReturnRows(NewRows([]string{"id"}).AddRow(1), NewRows([]string{"name"}).ColumnsError(errDescribe))
+
+	rows, err := db.Query("CALL user_report()")
+	if err != nil {
+		t.Fatalf("error was not expected, but got: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+	}
+	if rows.NextResultSet() {
+		t.Error("was not expecting the second result set to be available")
+	}
+	if rows.Err() != errDescribe {
+		t.Errorf("expected columns error of the second result set, but got: %v", rows.Err())
+	}
+}
+
+func TestRowsNextDelayFunc(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var indexes []int
+	rows := NewRows([]string{"id"}).AddRow(1).AddRow(2).AddRow(3).
+		NextDelayFunc(func(rowIndex int) time.Duration {
+			indexes = append(indexes, rowIndex)
+			if rowIndex == 2 {
+				return 50 * time.Millisecond
+			}
+			return 0
+		})
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+
+	rs, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer rs.Close()
+
+	start := time.Now()
+	for rs.Next() {
+	}
+	if err := rs.Err(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected rows to be delayed for at least 50ms, but it took %s", elapsed)
+	}
+	if fmt.Sprint(indexes) != "[0 1 2]" {
+		t.Errorf("expected delay func to be called for rows [0 1 2], but got %v", indexes)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRowsNextDelayFuncCancelled(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := NewRows([]string{"id"}).AddRow(1).AddRow(2).
+		NextDelayFunc(func(rowIndex int) time.Duration {
+			if rowIndex == 1 {
+				return time.Hour
+			}
+			return 0
+		})
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rs, err := db.QueryContext(ctx, "SELECT")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer rs.Close()
+
+	if !rs.Next() {
+		t.Fatalf("expected first row to be read, but got: %v", rs.Err())
+	}
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if rs.Next() {
+		t.Fatal("expected second row read to be cancelled")
+	}
+	if err := rs.Err(); err != ErrCancelled && err != context.Canceled {
+		t.Errorf("expected a cancellation error, but got: %v", err)
+	}
+}
+
+func TestRowsColumnTypeLength(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := mock.NewRowsWithColumnDefinition(
+		NewColumn("name").WithLength(255),
+		NewColumn("note"),
+	).AddRow("john", "admin")
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+	mock.ExpectQuery("SELECT").WillReturnRows(NewRows([]string{"name"}).AddRow("jane"))
+
+	for i, expected := range []struct {
+		length int64
+		ok     bool
+	}{{255, true}, {0, false}} {
+		rs, err := db.Query("SELECT")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		types, err := rs.ColumnTypes()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if length, ok := types[0].Length(); length != expected.length || ok != expected.ok {
+			t.Errorf("expected length %d (%t) of query %d column, but got %d (%t)", expected.length, expected.ok, i, length, ok)
+		}
+		rs.Close()
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRowsFirstRowDelay(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := NewRows([]string{"id"}).AddRow(1).AddRow(2).FirstRowDelay(50 * time.Millisecond)
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+
+	rs, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer rs.Close()
+
+	start := time.Now()
+	if !rs.Next() {
+		t.Fatalf("expected first row to be read, but got: %v", rs.Err())
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected first row to be delayed for at least 50ms, but it took %s", elapsed)
+	}
+	start = time.Now()
+	if !rs.Next() {
+		t.Fatalf("expected second row to be read, but got: %v", rs.Err())
+	}
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Errorf("expected second row not to be delayed, but it took %s", elapsed)
+	}
+
+	rows.FirstRowDelay(time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	rs2, err := db.QueryContext(ctx, "SELECT")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer rs2.Close()
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if rs2.Next() {
+		t.Error("expected first row read to be cancelled")
+	}
+}
+
 func TestQueryRowBytesInvalidatedByNext_jsonRawMessageIntoRawBytes(t *testing.T) {
 	t.Parallel()
 	replace := []byte(invalid)
@@ -203,3 +456,51 @@ func TestQueryRowBytesNotInvalidatedByClose_jsonRawMessageIntoCustomBytes(t *tes
 	}
 	queryRowBytesNotInvalidatedByClose(t, rows, scan, []byte(`{"thing": "one", "thing2": "two"}`))
 }
+
+func TestRowsWithWindowCountColumnDefinition(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	page := NewRowsWithColumnDefinition(NewColumn("name").WithLength(32)).
+		AddRow("john").
+		WithWindowCount("total", 7).
+		FromCSVString("jane")
+	mock.ExpectQuery("SELECT").WillReturnRows(page)
+
+	rs, err := db.Query("SELECT name, COUNT(*) OVER() AS total FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer rs.Close()
+
+	types, err := rs.ColumnTypes()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(types) != 2 || types[1].Name() != "total" {
+		t.Fatalf("expected column definitions to include the total column, but got %d columns", len(types))
+	}
+	if _, ok := types[1].Length(); ok {
+		t.Error("expected the total column not to define a length")
+	}
+
+	var totals []int64
+	for rs.Next() {
+		var name string
+		var total int64
+		if err := rs.Scan(&name, &total); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		totals = append(totals, total)
+	}
+	if !reflect.DeepEqual(totals, []int64{7, 7}) {
+		t.Errorf("expected every row to hold the total count, but got %v", totals)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
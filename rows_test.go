@@ -3,8 +3,12 @@ package sqlmock
 import (
 	"bytes"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 const invalid = `☠☠☠ MEMORY OVERWRITTEN ☠☠☠ `
@@ -231,6 +235,44 @@ func TestAllowsToSetRowsErrors(t *testing.T) {
 	}
 }
 
+func TestRowsDeferredError(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	deferred := fmt.Errorf("connection reset while streaming result")
+	rows := NewRows([]string{"id"}).AddRow(1).AddRow(2).DeferredError(deferred)
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+
+	rs, err := db.Query("SELECT id FROM users")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while selecting users", err)
+	}
+	defer rs.Close()
+
+	var ids []int
+	for rs.Next() {
+		var id int
+		if err := rs.Scan(&id); err != nil {
+			t.Fatalf("error '%s' was not expected, while scanning a row", err)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) != 2 {
+		t.Errorf("expected all rows to be read, but got %v", ids)
+	}
+	if err := rs.Err(); err != deferred {
+		t.Errorf("expected deferred error after all rows were read, but got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestRowsCloseError(t *testing.T) {
 	t.Parallel()
 	db, mock, err := New()
@@ -281,6 +323,563 @@ func TestRowsClosed(t *testing.T) {
 	}
 }
 
+func TestQueryNextTimings(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ex := mock.ExpectQuery("SELECT").WillReturnRows(NewRows([]string{"id"}).AddRow(1).AddRow(2))
+
+	rs, err := db.Query("SELECT id FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer rs.Close()
+	for rs.Next() {
+		time.Sleep(10 * time.Millisecond) // slow consumer
+	}
+
+	timings := ex.NextTimings()
+	if len(timings) != 3 {
+		t.Fatalf("expected 3 timed rows.Next calls, but got %d", len(timings))
+	}
+	for i := 1; i < len(timings); i++ {
+		if gap := timings[i].Sub(timings[i-1]); gap < 10*time.Millisecond {
+			t.Errorf("expected rows.Next call %d to be spaced out by the consumer, but it came after %s", i, gap)
+		}
+	}
+}
+
+func TestQueryExpectsSingleRow(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	users := NewRows([]string{"id"}).AddRow(1).AddRow(2)
+	mock.ExpectQuery("SELECT").ExpectsSingleRow().WillReturnRows(users)
+	mock.ExpectQuery("SELECT").ExpectsSingleRow().WillReturnRows(users)
+
+	var id int
+	if err = db.QueryRow("SELECT id FROM users WHERE email = ?", "john@example.com").Scan(&id); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := mock.ExpectationsWereMet(); err == nil {
+		t.Fatal("expected the second query to remain")
+	}
+
+	rs, err := db.Query("SELECT id FROM users WHERE email = ?", "john@example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var n int
+	for rs.Next() {
+		n++
+	}
+	rs.Close()
+	if n != 1 || rs.Err() == nil {
+		t.Errorf("expected reading a second row to fail, but read %d rows with error: %v", n, rs.Err())
+	}
+	if err := mock.ExpectationsWereMet(); err == nil {
+		t.Error("expected an error, since many rows were read")
+	}
+}
+
+func TestQueryRowsColumnsError(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	errDescribe := fmt.Errorf("could not describe result columns")
+	mock.ExpectQuery("SELECT").WillReturnRows(NewRows([]string{"id"}).AddRow(1).ColumnsError(errDescribe))
+
+	if _, err = db.Query("SELECT id FROM users"); err != errDescribe {
+		t.Errorf("expected the query to fail with columns error, but got: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expected the query to be triggered, but: %s", err)
+	}
+}
+
+func TestRowsDrained(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := NewRows([]string{"id"}).AddRow(1).AddRow(2)
+	drained := mock.ExpectQuery("SELECT id").WillReturnRows(rows).RowsWillBeDrained()
+	partial := mock.ExpectQuery("SELECT name").WillReturnRows(NewRows([]string{"name"}).AddRow("john").AddRow("jane"))
+
+	rs, err := db.Query("SELECT id")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for rs.Next() {
+	}
+	if err := rs.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rs, err = db.Query("SELECT name")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	rs.Next()
+	if err := rs.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !drained.RowsDrained() {
+		t.Error("expected rows to be reported as drained")
+	}
+	if partial.RowsDrained() {
+		t.Error("expected rows closed early not to be reported as drained")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRowsNotDrained(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := NewRows([]string{"id"}).AddRow(1).AddRow(2)
+	mock.ExpectQuery("SELECT").WillReturnRows(rows).RowsWillBeDrained()
+
+	rs, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	rs.Next()
+	if err := rs.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err == nil {
+		t.Fatal("expected an error, since rows were closed before they were drained")
+	}
+}
+
+func TestRowsNotDrainedOnAnEarlierCall(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := NewRows([]string{"id"}).AddRow(1).AddRow(2)
+	ex := mock.ExpectQuery("SELECT").WillReturnRows(rows).RowsWillBeDrained().Times(2)
+
+	rs, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	rs.Next()
+	if err := rs.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rs, err = db.Query("SELECT")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for rs.Next() {
+	}
+	if err := rs.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ex.RowsDrained() {
+		t.Error("expected rows not to be reported as drained, since the first call closed them early")
+	}
+	if err := mock.ExpectationsWereMet(); err == nil {
+		t.Fatal("expected an error, since the rows of the first call were closed before they were drained")
+	}
+}
+
+func TestRowsConsumedByInsertReturning(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ids := NewRows([]string{"id"}).AddRow(1).AddRow(2).AddRow(3)
+	insert := mock.ExpectQuery("INSERT INTO users (.+) RETURNING id").WillReturnRows(ids).RowsWillBeConsumed(3)
+
+	rs, err := db.Query("INSERT INTO users (name) VALUES ('a'), ('b'), ('c') RETURNING id")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for i := 0; i < 2 && rs.Next(); i++ {
+	}
+	if err := rs.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n := insert.RowsConsumed(); n != 2 {
+		t.Errorf("expected 2 rows to be consumed, but got %d", n)
+	}
+	err = mock.ExpectationsWereMet()
+	if err == nil || !strings.HasPrefix(err.Error(), "expected 3 query rows to be consumed, but 2 were read") {
+		t.Errorf("expected the missing returned id to be reported, but got: %v", err)
+	}
+}
+
+func TestRowsConsumedStopsAtRowError(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ids := NewRows([]string{"id"}).AddRow(1).AddRow(2).RowError(1, fmt.Errorf("conflict"))
+	insert := mock.ExpectQuery("INSERT INTO users (.+) RETURNING id").WillReturnRows(ids)
+
+	rs, err := db.Query("INSERT INTO users (name) VALUES ('a'), ('b') RETURNING id")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for rs.Next() {
+	}
+	if err := rs.Err(); err == nil {
+		t.Error("expected the row error to be returned")
+	}
+	rs.Close()
+
+	if n := insert.RowsConsumed(); n != 1 {
+		t.Errorf("expected the row with an error not to be consumed, but got %d consumed rows", n)
+	}
+}
+
+func TestRowsWithWindowCount(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	page := NewRows([]string{"id", "name"}).AddRow(1, "john").WithWindowCount("total", 42).AddRow(2, "jane")
+	mock.ExpectQuery("SELECT id, name, COUNT(.+) OVER\\(\\) AS total FROM users").WillReturnRows(page)
+
+	rs, err := db.Query("SELECT id, name, COUNT(*) OVER() AS total FROM users LIMIT 2")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer rs.Close()
+
+	var totals []int64
+	for rs.Next() {
+		var id, total int64
+		var name string
+		if err := rs.Scan(&id, &name, &total); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		totals = append(totals, total)
+	}
+	if !reflect.DeepEqual(totals, []int64{42, 42}) {
+		t.Errorf("expected every row to hold the total count, but got %v", totals)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRowsDuplicateColumnNames(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New(StrictColumnOrderOption(), RequireAllColumnsSelectedOption(), CheckColumnCountOption())
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := NewRows([]string{"id", "name", "id"}).AddRow(1, "john", 10).AddRow(2, "jane", 20)
+	mock.ExpectQuery("SELECT u.id, u.name, o.id FROM users u JOIN orders o").WillReturnRows(rows)
+
+	rs, err := db.Query("SELECT u.id, u.name, o.id FROM users u JOIN orders o ON o.user_id = u.id")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer rs.Close()
+
+	cols, err := rs.Columns()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(cols, []string{"id", "name", "id"}) {
+		t.Errorf("expected duplicate columns to be reported as declared, but got %v", cols)
+	}
+
+	for rs.Next() {
+		var userID, orderID int64
+		var name string
+		if err := rs.Scan(&userID, &name, &orderID); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if orderID != userID*10 {
+			t.Errorf("expected values to be scanned by their position, but got user %d and order %d", userID, orderID)
+		}
+	}
+
+	if filtered := rows.FilterByColumn("id", 2); len(filtered.rows) != 1 || filtered.rows[0][2] != int64(20) {
+		t.Errorf("expected the first id column to be filtered by, but got %v", filtered.rows)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	// repeated names are compared position by position
+	mock.ExpectQuery("SELECT u.name, u.id, o.id FROM users u JOIN orders o").WillReturnRows(rows)
+	_, err = db.Query("SELECT u.name, u.id, o.id FROM users u JOIN orders o ON o.user_id = u.id")
+	if err == nil || !strings.Contains(err.Error(), "selected columns [name id id] do not match the order of declared rows columns [id name id]") {
+		t.Errorf("expected the strict column order to compare repeated names by position, but got: %v", err)
+	}
+}
+
+func TestRowsBatchSize(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := NewRows([]string{"id"}).BatchSize(2).BatchDelay(20 * time.Millisecond)
+	for i := 1; i <= 5; i++ {
+		rows.AddRow(i)
+	}
+	ex := mock.ExpectQuery("SELECT id FROM events").WillReturnRows(rows).RowsWillBeDrained()
+
+	rs, err := db.Query("SELECT id FROM events")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	start := time.Now()
+	var read int
+	for rs.Next() {
+		read++
+	}
+	if err := rs.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if read != 5 {
+		t.Errorf("expected 5 rows to be read, but got %d", read)
+	}
+	if n := ex.BatchesFetched(); n != 3 {
+		t.Errorf("expected 3 batches to be fetched, but got %d", n)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("expected fetching of the following batches to be delayed, but reading took %s", elapsed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStrictColumnOrder(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New(StrictColumnOrderOption())
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name FROM users").WillReturnRows(NewRows([]string{"id", "name"}).AddRow(1, "john"))
+	mock.ExpectQuery("SELECT name, id FROM users").WillReturnRows(NewRows([]string{"id", "name"}).AddRow(1, "john"))
+
+	rs, err := db.Query("SELECT id, name FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	rs.Close()
+
+	_, err = db.Query("SELECT name, id FROM users")
+	if err == nil {
+		t.Fatal("expected an error, since selected columns are in a different order")
+	}
+
+	expected := "Query 'SELECT name, id FROM users', selected columns [name id] do not match the order of declared rows columns [id name]"
+	if err.Error() != expected {
+		t.Errorf("expected error '%s', but got '%s'", expected, err)
+	}
+}
+
+func TestRequireAllColumnsSelected(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New(RequireAllColumnsSelectedOption())
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	users := NewRows([]string{"id", "name", "email"}).AddRow(1, "john", "john@example.com")
+	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(users)
+	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(users)
+
+	rs, err := db.Query("SELECT email, id, name FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	rs.Close()
+
+	_, err = db.Query("SELECT u.id, u.name FROM users u")
+	expected := "Query 'SELECT u.id, u.name FROM users u', selected columns [id name] would not scan declared rows columns [email]"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s', but got '%v'", expected, err)
+	}
+}
+
+func TestCheckColumnCount(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New(CheckColumnCountOption())
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	users := NewRows([]string{"id", "name"}).AddRow(1, "john")
+	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(users)
+	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(users)
+	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(users)
+
+	rs, err := db.Query("SELECT id, upper(name) FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	rs.Close()
+	if rs, err = db.Query("SELECT * FROM users"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	rs.Close()
+
+	_, err = db.Query("SELECT id, name, email FROM users")
+	expected := "Query 'SELECT id, name, email FROM users', query selects 3 columns, but rows declare 2 columns [id name]"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s', but got '%v'", expected, err)
+	}
+}
+
+func TestRowsValidateScannable(t *testing.T) {
+	t.Parallel()
+	rows := NewRows([]string{"id", "name", "created"}).
+		AddRow(1, "john", time.Now()).
+		AddRow("two", nil, "yesterday").
+		RowError(1, fmt.Errorf("ignored"))
+
+	types := []reflect.Type{reflect.TypeOf(int64(0)), reflect.TypeOf(""), reflect.TypeOf(time.Time{})}
+	errs := rows.ValidateScannable(types)
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 cells not to be scannable, but got %d: %v", len(errs), errs)
+	}
+	for _, err := range errs {
+		if !strings.HasPrefix(err.Error(), "row #2: sql: Scan error on column index") {
+			t.Errorf("unexpected validation error: %s", err)
+		}
+	}
+
+	if errs := rows.ValidateScannable([]reflect.Type{nil, reflect.TypeOf(sql.NullString{}), nil}); len(errs) != 0 {
+		t.Errorf("expected all cells to be scannable, but got: %v", errs)
+	}
+	if errs := rows.ValidateScannable(types[:1]); len(errs) != 1 {
+		t.Errorf("expected an error, since destination types do not match columns, but got: %v", errs)
+	}
+}
+
+func TestRowsValidateScannableLazyValueConvertError(t *testing.T) {
+	t.Parallel()
+	rows := NewRows([]string{"id"}).
+		AddRow(1).
+		AddRow(func() driver.Value { return struct{}{} })
+
+	errs := rows.ValidateScannable([]reflect.Type{reflect.TypeOf(int64(0))})
+	if len(errs) != 1 {
+		t.Fatalf("expected an error, since lazy value cannot be converted, but got: %v", errs)
+	}
+	if !strings.Contains(errs[0].Error(), "row #2, column #0 (\"id\") lazy value") {
+		t.Errorf("unexpected validation error: %s", errs[0])
+	}
+}
+
+func TestRowsFromMap(t *testing.T) {
+	t.Parallel()
+	rows := RowsFromMap("code", "rate", map[string]int{"usd": 1, "eur": 2, "gbp": 3, "chf": 4})
+	expected := [][]driver.Value{{"chf", int64(4)}, {"eur", int64(2)}, {"gbp", int64(3)}, {"usd", int64(1)}}
+	if !reflect.DeepEqual(rows.rows, expected) {
+		t.Errorf("expected rows to be sorted by key as %v, but got %v", expected, rows.rows)
+	}
+
+	ints := RowsFromMap("id", "name", map[int]string{10: "ten", 9: "nine", -1: "minus one"})
+	if first := ints.rows[0][0]; first != int64(-1) {
+		t.Errorf("expected integer keys to be sorted numerically, but the first key was %v", first)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic, since rows were not given a map")
+		}
+	}()
+	RowsFromMap("key", "value", []string{"not", "a", "map"})
+}
+
+func TestJSONAggColumn(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	children := []map[string]interface{}{
+		{"sku": "a-1", "qty": 2, "id": 1},
+		{"sku": "b-2", "qty": 1, "id": 2},
+	}
+	mock.ExpectQuery("SELECT json_agg").WillReturnRows(JSONAggColumn("items", children))
+	mock.ExpectQuery("SELECT json_agg").WillReturnRows(JSONAggColumn("items", nil))
+
+	var items string
+	if err = db.QueryRow("SELECT json_agg(i) AS items FROM order_items i").Scan(&items); err != nil {
+		t.Fatalf("error '%s' was not expected, while selecting items", err)
+	}
+	expected := `[{"id":1,"qty":2,"sku":"a-1"},{"id":2,"qty":1,"sku":"b-2"}]`
+	if items != expected {
+		t.Errorf("expected items to be '%s', but got '%s'", expected, items)
+	}
+
+	var none sql.NullString
+	if err = db.QueryRow("SELECT json_agg(i) AS items FROM order_items i").Scan(&none); err != nil {
+		t.Fatalf("error '%s' was not expected, while selecting items", err)
+	}
+	if none.Valid {
+		t.Errorf("expected items to be NULL without children, but got '%s'", none.String)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestQuerySingleRow(t *testing.T) {
 	t.Parallel()
 	db, mock, err := New()
@@ -309,6 +908,44 @@ func TestQuerySingleRow(t *testing.T) {
 	}
 }
 
+func TestQueryZeroColumns(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("CREATE TABLE").WillReturnRows(NewRows([]string{}))
+
+	rs, err := db.Query("CREATE TABLE users (id INT)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer rs.Close()
+
+	cols, err := rs.Columns()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(cols) != 0 {
+		t.Fatalf("expected zero columns, but got: %v", cols)
+	}
+
+	if rs.Next() {
+		t.Fatal("expected no rows to be returned")
+	}
+
+	if err := rs.Err(); err != nil {
+		t.Fatalf("unexpected rows error: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestQueryRowBytesInvalidatedByNext_bytesIntoRawBytes(t *testing.T) {
 	t.Parallel()
 	replace := []byte(invalid)
@@ -670,3 +1307,78 @@ func queryRowBytesNotInvalidatedByClose(t *testing.T, rows *Rows, scan func(*sql
 		t.Fatal(err)
 	}
 }
+
+func TestRowsLazyValues(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var calls int
+	lazy := func(v int) func() driver.Value {
+		return func() driver.Value {
+			calls++
+			return v
+		}
+	}
+
+	rows := NewRows([]string{"id", "score"}).
+		AddRow(1, lazy(10)).
+		AddRow(2, lazy(20)).
+		AddRow(3, lazy(30))
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+
+	rs, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer rs.Close()
+
+	if calls != 0 {
+		t.Fatalf("expected lazy values not to be evaluated before read, but got %d calls", calls)
+	}
+
+	var id, score int
+	if !rs.Next() {
+		t.Fatal("expected a row to be returned")
+	}
+	if err := rs.Scan(&id, &score); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if score != 10 {
+		t.Errorf("expected lazy score to be 10, but got %d", score)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected lazy values to be evaluated once per row read, but got %d calls", calls)
+	}
+}
+
+func TestRowsLazyValueConvertError(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := NewRows([]string{"id"}).AddRow(func() driver.Value { return struct{}{} })
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+
+	rs, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer rs.Close()
+
+	if rs.Next() {
+		t.Fatal("expected no row to be read, since lazy value cannot be converted")
+	}
+
+	if rs.Err() == nil {
+		t.Fatal("expected rows error, since lazy value cannot be converted")
+	}
+}
@@ -0,0 +1,96 @@
+package sqlmock
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var savepointCommand = regexp.MustCompile(`(?is)^\s*(SAVEPOINT|RELEASE(?:\s+SAVEPOINT)?|ROLLBACK(?:\s+WORK|\s+TRANSACTION)?\s+TO(?:\s+SAVEPOINT)?)\s+("[^"]+"|[A-Za-z_]\w*)\s*;?\s*$`)
+
+// savepoint is a savepoint established within a transaction
+type savepoint struct {
+	name       string
+	rolledBack bool // rolled back to, which keeps it established
+}
+
+// savepoint tracks nesting of savepoints established by the
+// connection, when query is a SAVEPOINT, RELEASE SAVEPOINT or
+// ROLLBACK TO SAVEPOINT command
+func (c *conn) savepoint(query string) {
+	m := savepointCommand.FindStringSubmatch(query)
+	if m == nil {
+		return
+	}
+	command := strings.ToUpper(strings.Fields(m[1])[0])
+	name := strings.Trim(m[2], `"`)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.savepoints == nil {
+		c.savepoints = make(map[*conn][]savepoint)
+	}
+	stack := c.savepoints[c]
+	if command == "SAVEPOINT" {
+		stack = append(stack, savepoint{name: name})
+		if len(stack) > c.maxSavepoints {
+			c.maxSavepoints = len(stack)
+		}
+		c.savepoints[c] = stack
+		return
+	}
+
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].name != name {
+			continue
+		}
+		if command == "RELEASE" {
+			stack = stack[:i]
+		} else {
+			stack[i].rolledBack = true
+			stack = stack[:i+1]
+		}
+		break
+	}
+	c.savepoints[c] = stack
+}
+
+// endSavepoints discards savepoints of the connection, when its
+// transaction ends, remembering those which were never resolved
+func (c *conn) endSavepoints() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, sp := range c.savepoints[c] {
+		if !sp.rolledBack {
+			c.unreleased = append(c.unreleased, sp.name)
+		}
+	}
+	delete(c.savepoints, c)
+}
+
+// MaxSavepointDepth returns the deepest savepoint nesting
+// reached by any connection so far
+func (c *sqlmock) MaxSavepointDepth() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.maxSavepoints
+}
+
+// AssertSavepointsReleased checks that every savepoint was
+// either released or rolled back to
+func (c *sqlmock) AssertSavepointsReleased() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := append([]string{}, c.unreleased...)
+	for _, stack := range c.savepoints {
+		for _, sp := range stack {
+			if !sp.rolledBack {
+				names = append(names, sp.name)
+			}
+		}
+	}
+	if len(names) > 0 {
+		return fmt.Errorf("savepoints %v were neither released nor rolled back to", names)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package sqlmock
+
+import "testing"
+
+func TestSavepointDepth(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT sp1").WillReturnResult(NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT sp2").WillReturnResult(NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT sp2").WillReturnResult(NewResult(0, 0))
+	mock.ExpectExec("RELEASE SAVEPOINT sp1").WillReturnResult(NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT sp3").WillReturnResult(NewResult(0, 0))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while beginning a transaction", err)
+	}
+	for _, query := range []string{"SAVEPOINT sp1", "SAVEPOINT sp2", "ROLLBACK TO SAVEPOINT sp2", "RELEASE SAVEPOINT sp1"} {
+		if _, err = tx.Exec(query); err != nil {
+			t.Fatalf("error '%s' was not expected, while executing '%s'", err, query)
+		}
+	}
+	if err = mock.AssertSavepointsReleased(); err != nil {
+		t.Errorf("expected all savepoints to be released, but: %s", err)
+	}
+
+	if _, err = tx.Exec("SAVEPOINT sp3"); err != nil {
+		t.Fatalf("error '%s' was not expected, while establishing a savepoint", err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("error '%s' was not expected, while committing a transaction", err)
+	}
+
+	if depth := mock.MaxSavepointDepth(); depth != 2 {
+		t.Errorf("expected savepoints to be nested 2 deep, but got %d", depth)
+	}
+	expected := "savepoints [sp3] were neither released nor rolled back to"
+	if err = mock.AssertSavepointsReleased(); err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s', but got '%v'", expected, err)
+	}
+}
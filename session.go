@@ -0,0 +1,75 @@
+package sqlmock
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// ExpectedResetSession is used to manage the session reset, which
+// database/sql does before it reuses an idle connection of its pool.
+// Returned by *Sqlmock.ExpectResetSession.
+//
+// Session resets are matched regardless of other expectations and
+// their order, since database/sql resets sessions implicitly. Every
+// reset matches the next expected one, while resets not expected
+// succeed.
+type ExpectedResetSession struct {
+	commonExpectation
+}
+
+// WillReturnError allows to set an error for the session reset.
+// database/sql then discards the connection and opens a new one.
+func (e *ExpectedResetSession) WillReturnError(err error) *ExpectedResetSession {
+	e.err = err
+	return e
+}
+
+// String returns string representation
+func (e *ExpectedResetSession) String() string {
+	msg := "ExpectedResetSession => expecting session of an idle connection to be reset"
+	if e.err != nil {
+		msg += fmt.Sprintf(", which should return error: %s", e.err)
+	}
+	return msg
+}
+
+func (c *sqlmock) ExpectResetSession() *ExpectedResetSession {
+	e := &ExpectedResetSession{}
+	c.mu.Lock()
+	c.resets = append(c.resets, e)
+	c.mu.Unlock()
+	return e
+}
+
+// resetSession matches the next expected session reset
+func (c *conn) resetSession() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range c.resets {
+		if e.triggered {
+			continue
+		}
+		e.triggered = true
+		if e.err != nil {
+			// database/sql compares the error to driver.ErrBadConn
+			// before go1.18, it never returns it to the caller
+			c.resetFailed = true
+			return driver.ErrBadConn
+		}
+		return nil
+	}
+	return nil
+}
+
+// resetsWereMet checks whether all expected
+// session resets were done
+func (c *sqlmock) resetsWereMet() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range c.resets {
+		if !e.triggered {
+			return fmt.Errorf("there is a remaining expectation which was not matched: %s", e)
+		}
+	}
+	return nil
+}
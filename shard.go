@@ -0,0 +1,258 @@
+// +build go1.8
+
+package sqlmock
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+)
+
+var shardPool *shardDriver
+
+func init() {
+	shardPool = &shardDriver{
+		routers: make(map[string]*shardRouter),
+	}
+	sql.Register("sqlmock_sharded", shardPool)
+}
+
+// ShardSelector picks the zero based index of the shard, which
+// a query or exec with the given arguments is routed to. Queries
+// are prepared with no arguments, so a selector must be able to
+// route prepared statements by the query alone.
+type ShardSelector func(query string, args []driver.NamedValue) (int, error)
+
+type shardDriver struct {
+	sync.Mutex
+	counter int
+	routers map[string]*shardRouter
+}
+
+type shardRouter struct {
+	dsn      string
+	shards   []*sqlmock
+	selector ShardSelector
+	opened   int // guarded by the shard driver lock
+}
+
+func (d *shardDriver) Open(dsn string) (driver.Conn, error) {
+	r, err := d.router(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return r.connect(func(i int) (driver.Conn, error) {
+		return pool.Open(r.shards[i].dsn)
+	})
+}
+
+func (d *shardDriver) router(dsn string) (*shardRouter, error) {
+	d.Lock()
+	defer d.Unlock()
+	r, ok := d.routers[dsn]
+	if !ok {
+		return nil, fmt.Errorf("expected a sharded connection to be available, but it is not")
+	}
+	return r, nil
+}
+
+// connect opens a connection to every shard, with the
+// given func opening the driver connection of a shard
+func (r *shardRouter) connect(open func(i int) (driver.Conn, error)) (driver.Conn, error) {
+	shardPool.Lock()
+	r.opened++
+	shardPool.Unlock()
+
+	c := &shardConn{shardRouter: r}
+	for i := range r.shards {
+		dc, err := open(i)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.conns = append(c.conns, unwrapConn(dc))
+	}
+	return c, nil
+}
+
+// NewSharded creates a sqlmock database connection, which routes
+// every call to one of the given number of shards, as picked by
+// the selector. Every shard is an independent mock, which manages
+// its own expectations, so that shard routing logic can be tested
+// against distinct expectation sets behind a single *sql.DB.
+// Options are applied to every shard. The database is closed if
+// the initial ping of the shards fails.
+//
+// A transaction is bound to the shard of its first statement, the
+// shard transaction is begun only then. Statements of the
+// transaction routed to another shard fail. A transaction without
+// statements is committed or rolled back without reaching a shard.
+func NewSharded(shards int, selector ShardSelector, options ...func(*sqlmock) error) (*sql.DB, []Sqlmock, error) {
+	if shards < 1 {
+		return nil, nil, fmt.Errorf("expected at least one shard, but got %d", shards)
+	}
+
+	r := &shardRouter{selector: selector}
+	mocks := make([]Sqlmock, shards)
+	for i := range mocks {
+		pool.Lock()
+		dsn := fmt.Sprintf("sqlmock_db_%d", pool.counter)
+		pool.counter++
+		smock := &sqlmock{dsn: dsn, drv: pool, ordered: true}
+		pool.conns[dsn] = smock
+		pool.Unlock()
+
+		if err := smock.configure(options); err != nil {
+			pool.Lock()
+			for _, shard := range append(r.shards, smock) {
+				delete(pool.conns, shard.dsn)
+			}
+			pool.Unlock()
+			return nil, nil, err
+		}
+		r.shards = append(r.shards, smock)
+		mocks[i] = smock
+	}
+
+	shardPool.Lock()
+	r.dsn = fmt.Sprintf("sqlmock_sharded_%d", shardPool.counter)
+	shardPool.counter++
+	shardPool.routers[r.dsn] = r
+	shardPool.Unlock()
+
+	db, err := sql.Open("sqlmock_sharded", r.dsn)
+	if err != nil {
+		return db, mocks, err
+	}
+	if err := db.Ping(); err != nil {
+		// closing the connections releases the router and shards
+		db.Close()
+		return nil, nil, err
+	}
+	return db, mocks, nil
+}
+
+// shardConn holds a driver connection to every shard
+type shardConn struct {
+	*shardRouter
+	conns []*conn
+	tx    *shardTx
+}
+
+type shardTx struct {
+	c     *shardConn
+	ctx   context.Context
+	opts  driver.TxOptions
+	shard int // -1 until the first statement
+	tx    driver.Tx
+}
+
+func (t *shardTx) Commit() error {
+	t.c.tx = nil
+	if t.tx == nil {
+		return nil
+	}
+	return t.tx.Commit()
+}
+
+func (t *shardTx) Rollback() error {
+	t.c.tx = nil
+	if t.tx == nil {
+		return nil
+	}
+	return t.tx.Rollback()
+}
+
+// route picks the shard connection for the query and binds
+// the pending transaction to it, if there is one
+func (c *shardConn) route(query string, args []driver.NamedValue) (*conn, error) {
+	i, err := c.selector(query, args)
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 || i >= len(c.conns) {
+		return nil, fmt.Errorf("query '%s' was routed to shard %d, but there are only %d shards", query, i, len(c.conns))
+	}
+
+	if c.tx == nil {
+		return c.conns[i], nil
+	}
+	if c.tx.shard == -1 {
+		tx, err := c.conns[i].BeginTx(c.tx.ctx, c.tx.opts)
+		if err != nil {
+			return nil, err
+		}
+		c.tx.shard, c.tx.tx = i, tx
+	}
+	if c.tx.shard != i {
+		return nil, fmt.Errorf("query '%s' was routed to shard %d, but the transaction is bound to shard %d", query, i, c.tx.shard)
+	}
+	return c.conns[i], nil
+}
+
+func (c *shardConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *shardConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	sc, err := c.route(query, nil)
+	if err != nil {
+		return nil, err
+	}
+	return sc.PrepareContext(ctx, query)
+}
+
+func (c *shardConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *shardConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.tx = &shardTx{c: c, ctx: ctx, opts: opts, shard: -1}
+	return c.tx, nil
+}
+
+func (c *shardConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	sc, err := c.route(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return sc.QueryContext(ctx, query, args)
+}
+
+func (c *shardConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	sc, err := c.route(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return sc.ExecContext(ctx, query, args)
+}
+
+// Ping pings every shard
+func (c *shardConn) Ping(ctx context.Context) error {
+	for _, sc := range c.conns {
+		if err := sc.Ping(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the connection to every shard, the router
+// is released once its last connection is closed
+func (c *shardConn) Close() (err error) {
+	for _, sc := range c.conns {
+		if cerr := sc.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	shardPool.Lock()
+	c.opened--
+	if c.opened == 0 {
+		delete(shardPool.routers, c.dsn)
+	}
+	shardPool.Unlock()
+	return err
+}
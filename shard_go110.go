@@ -0,0 +1,53 @@
+// +build go1.10
+
+package sqlmock
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// shardConnector opens sharded connections through a connector
+// of every shard, so that sql.DB.Close closes them all
+type shardConnector struct {
+	drv    *shardDriver
+	router *shardRouter
+	shards []*connector
+}
+
+// OpenConnector implements driver.DriverContext interface
+func (d *shardDriver) OpenConnector(dsn string) (driver.Connector, error) {
+	r, err := d.router(dsn)
+	if err != nil {
+		return nil, err
+	}
+	c := &shardConnector{drv: d, router: r}
+	for _, shard := range r.shards {
+		sc, err := pool.OpenConnector(shard.dsn)
+		if err != nil {
+			return nil, err
+		}
+		c.shards = append(c.shards, sc.(*connector))
+	}
+	return c, nil
+}
+
+// Connect implements driver.Connector interface
+func (c *shardConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.router.connect(func(i int) (driver.Conn, error) {
+		return c.shards[i].Connect(ctx)
+	})
+}
+
+// Driver implements driver.Connector interface
+func (c *shardConnector) Driver() driver.Driver {
+	return c.drv
+}
+
+// Close implements io.Closer interface
+func (c *shardConnector) Close() error {
+	for _, sc := range c.shards {
+		sc.Close()
+	}
+	return nil
+}
@@ -14,6 +14,9 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -45,6 +48,16 @@ type Sqlmock interface {
 	// the *ExpectedExec allows to mock database response
 	ExpectExec(expectedSQL string) *ExpectedExec
 
+	// ExpectQueryRegexp is the same as ExpectQuery, but matches the
+	// query by an already compiled regular expression, regardless
+	// of the configured QueryMatcher.
+	ExpectQueryRegexp(re *regexp.Regexp) *ExpectedQuery
+
+	// ExpectExecRegexp is the same as ExpectExec, but matches the
+	// exec by an already compiled regular expression, regardless
+	// of the configured QueryMatcher.
+	ExpectExecRegexp(re *regexp.Regexp) *ExpectedExec
+
 	// ExpectBegin expects *sql.DB.Begin to be called.
 	// the *ExpectedBegin allows to mock database response
 	ExpectBegin() *ExpectedBegin
@@ -53,6 +66,11 @@ type Sqlmock interface {
 	// the *ExpectedCommit allows to mock database response
 	ExpectCommit() *ExpectedCommit
 
+	// ExpectTx expects a transaction to be begun, to run the given
+	// steps, built by Exec and Query, in order and to be committed.
+	// The *ExpectedCommit allows to mock the Commit response.
+	ExpectTx(steps []TxStep) *ExpectedCommit
+
 	// ExpectRollback expects *sql.Tx.Rollback to be called.
 	// the *ExpectedRollback allows to mock database response
 	ExpectRollback() *ExpectedRollback
@@ -61,6 +79,34 @@ type Sqlmock interface {
 	// the *ExpectedPing allows to mock database response
 	ExpectPing() *ExpectedPing
 
+	// OnConnect registers fn to set up expectations of every connection
+	// opened from now on, like a handshake "SET" exec run by connection
+	// initialization hooks. fn is called right after the connection is
+	// opened. Until all the expectations set up by fn are met, execs and
+	// queries on the connection are matched only against them, in the
+	// same way other expectations are matched, while any other call on
+	// it fails. The connection opened by New to ping the database is not
+	// affected.
+	OnConnect(fn func(scope ConnScope))
+
+	// ExpectResetSession expects database/sql to reset the session of
+	// an idle connection, before it is reused. Resets are matched in
+	// the order they were expected, but regardless of other expectations.
+	// With an error, the connection is discarded, as if it failed its
+	// health check, and a new one is opened. The error is reported as
+	// driver.ErrBadConn by errors.Is, so that database/sql discards the
+	// connection. Older database/sql, which compares errors directly,
+	// requires the error to be driver.ErrBadConn itself.
+	ExpectResetSession() *ExpectedResetSession
+
+	// ExpectPhase groups the given expectations into a named phase
+	// of the workflow, like all queries of one table. No query or exec
+	// expected in a phase may be matched, until all expectations of
+	// phases declared before were met. It is coarser than matching
+	// expectations in order, since statements within a phase may
+	// still be matched in any order, if the mock is not ordered.
+	ExpectPhase(name string, expectations ...Expectation)
+
 	// MatchExpectationsInOrder gives an option whether to match all
 	// expectations in the order they were set or not.
 	//
@@ -78,17 +124,209 @@ type Sqlmock interface {
 	// sql driver.Value slice or from the CSV string and
 	// to be used as sql driver.Rows.
 	NewRows(columns []string) *Rows
+
+	// NewRowsWithColumnDefinition allows Rows to be created from
+	// column definitions and to be used as sql driver.Rows.
+	NewRowsWithColumnDefinition(columns ...*Column) *Rows
+
+	// Calls returns all database calls matched by expectations
+	// so far, in the order they were made. Each call describes
+	// the connection it was made on and whether it was made
+	// within a transaction.
+	Calls() []Call
+
+	// RePrepareCount returns how many times the statement with the
+	// given SQL was prepared again by database/sql on a new connection,
+	// after the connection it was prepared on was lost, that is it was
+	// poisoned or returned driver.ErrBadConn while the statement was
+	// open. Unless a pending ExpectPrepare matches it, such prepare
+	// matches the already triggered ExpectPrepare expectation, so that
+	// its statement expectations keep matching.
+	RePrepareCount(stmtSQL string) int
+
+	// PrepareHistory returns every statement prepared so far, with the
+	// connection it was prepared on and its SQL as received by the
+	// driver, so that SQL re-prepared on a new connection may be
+	// compared to the original.
+	PrepareHistory() []PrepareRecord
+
+	// StatementStats returns the whole lifecycle of the statement with
+	// the given SQL, in one place: how many times it was prepared, also
+	// again after connection loss, executed, reused and closed.
+	StatementStats(stmtSQL string) StmtStats
+
+	// CallCount returns the number of calls of the given kind, like
+	// CallExec, matched so far, in order to compare it to the number
+	// of records processed by batching code.
+	CallCount(kind CallKind) int
+
+	// AssertCallCountAtMost checks that at most n calls of the given
+	// kind were matched so far, like when batching is expected to
+	// reduce the number of round trips.
+	AssertCallCountAtMost(kind CallKind, n int) error
+
+	// AssertCalledOnce checks that exactly one of the calls matched
+	// so far has SQL matching pattern, using the mock query matcher,
+	// regardless of whether expectations are matched in order.
+	AssertCalledOnce(pattern string) error
+
+	// AssertStableFingerprints checks that all calls matched by the
+	// same expectation had the same fingerprint, that is the same
+	// normalized SQL, as used by statement caches for their keys.
+	AssertStableFingerprints() error
+
+	// TxStatementStats reports how statements were prepared and
+	// executed on prepared statements, within each transaction.
+	TxStatementStats() []TxStatementStats
+
+	// AssertStatementReuseInTx checks that within every transaction,
+	// statements matching pattern were prepared only once and reused
+	// at least minReuse times, instead of being prepared again.
+	AssertStatementReuseInTx(pattern string, minReuse int) error
+
+	// AutoIncrementCounter returns the last id allocated by execs
+	// expected with WillReturnAutoIncrementId, zero if none was.
+	AutoIncrementCounter() int64
+
+	// AssertAllInTransaction checks that every exec and query matched
+	// so far was made within a transaction, rather than in auto-commit
+	// mode on the pool, to enforce a unit of work is fully transactional.
+	AssertAllInTransaction() error
+
+	// AllBoundArgs returns argument values bound to every exec and
+	// query, one slice per call in the order calls were made, whether
+	// the call matched an expectation or not. It may be used to assert
+	// that sensitive data was never sent to the database.
+	AllBoundArgs() [][]driver.Value
+
+	// ExpectPrepareTransaction expects a "PREPARE TRANSACTION 'gid'"
+	// exec, which prepares the current transaction for two-phase commit.
+	// The prepared transaction must be resolved by a matched
+	// "COMMIT PREPARED 'gid'" or "ROLLBACK PREPARED 'gid'" exec,
+	// otherwise ExpectationsWereMet reports it.
+	ExpectPrepareTransaction(gid string) *ExpectedTwoPhase
+
+	// ExpectCommitPrepared expects a "COMMIT PREPARED 'gid'" exec.
+	ExpectCommitPrepared(gid string) *ExpectedTwoPhase
+
+	// ExpectRollbackPrepared expects a "ROLLBACK PREPARED 'gid'" exec.
+	ExpectRollbackPrepared(gid string) *ExpectedTwoPhase
+
+	// MaxSavepointDepth returns the deepest nesting of savepoints
+	// established by matched "SAVEPOINT name" execs within a single
+	// transaction, as tracked together with "RELEASE SAVEPOINT name"
+	// and "ROLLBACK TO SAVEPOINT name" execs.
+	MaxSavepointDepth() int
+
+	// AssertSavepointsReleased checks that every savepoint was either
+	// released or rolled back to, before its transaction ended.
+	AssertSavepointsReleased() error
+
+	// ExpectCursor expects a server side cursor with the given name
+	// to be declared by a "DECLARE name CURSOR FOR query" exec, and to
+	// be closed by a "CLOSE name" exec. Every "FETCH count FROM name"
+	// query made in between returns the next batch of count rows.
+	// Cursor statements are not recorded as calls, see Calls.
+	ExpectCursor(name string, rows *Rows) *ExpectedCursor
+
+	// Notify delivers a simulated notification to every connection,
+	// which executed a matched "LISTEN channel" exec and did not
+	// "UNLISTEN" it since. Code under test may wait for notifications
+	// by calling WaitForNotification on the driver connection, see
+	// NotificationWaiter.
+	Notify(channel, payload string)
+
+	// AssertListenerPinned checks that every wait for notifications
+	// was made on a connection, which executed a "LISTEN channel"
+	// exec before. Waiting on another connection of the pool is
+	// a common source of missed notifications.
+	AssertListenerPinned(channel string) error
+
+	// MaxConcurrentConnections returns the peak number of database
+	// connections, which were open at the same time. It may be used
+	// to assert that sql.DB.SetMaxOpenConns constrained concurrency.
+	MaxConcurrentConnections() int
+
+	// ConnectionReuseCount returns how many times database/sql reused
+	// an idle connection from its pool, instead of opening a new one.
+	// Reuse is detected by database/sql resetting the session of the
+	// connection, which requires Go 1.10 or later.
+	ConnectionReuseCount() int
+
+	// PoisonConnection marks all connections opened so far as bad.
+	// Any further operation on such connection fails with
+	// driver.ErrBadConn, so that database/sql discards it, while
+	// connections opened afterwards are healthy. Closing a poisoned
+	// connection is not matched against ExpectClose expectations.
+	PoisonConnection()
+
+	// DistinctQueries returns fingerprints of the distinct SQL
+	// statements matched so far, in order of the first call, see
+	// Call.Fingerprint.
+	DistinctQueries() []string
+
+	// DistinctQueryCount returns the number of distinct SQL
+	// statements matched so far.
+	DistinctQueryCount() int
 }
 
 type sqlmock struct {
 	ordered      bool
 	dsn          string
 	opened       int
+	connections  int
+	poisoned     int
+	peakOpened   int
+	reused       int
 	drv          *mockDriver
 	converter    driver.ValueConverter
 	queryMatcher QueryMatcher
 
+	strictPlaceholders bool
+	requireArgs        bool
+	strictColumnOrder  bool
+	allColumnsSelected bool
+	columnCount        bool
+	timeline           *TxTimeline
+	timelineName       string
+	rejectNamedArgs    bool
+	contextOnly        bool
+	skipValueCheck     bool
+	tableAllowlist     map[string]bool
+	quoteAgnostic      bool
+	abortTxErr         error
+	trailingPredicate  *regexp.Regexp
+
+	maxDelay time.Duration
+	logDelay func(format string, args ...interface{})
+
+	onMatch   func(call Call)
+	onConnect func(scope ConnScope)
+
 	expected []expectation
+	phases   []*phase
+
+	mu         sync.Mutex
+	calls      []Call
+	rePrepares map[string]int
+	listeners  map[*conn]*listener
+	waits      []wait
+	txs        int
+	txStats    []*TxStatementStats
+	stmts      map[string]*StmtStats
+	bound      [][]driver.Value
+	prepares   []PrepareRecord
+	store      rowStore
+
+	lastAutoIncrID int64 // last id allocated by WillReturnAutoIncrementId
+
+	savepoints    map[*conn][]savepoint
+	maxSavepoints int
+	unreleased    []string
+	cursors       []*ExpectedCursor
+	resets        []*ExpectedResetSession
+	handshakes    []*conn         // connections with handshake expectations
+	globalTxs     map[string]bool // prepared two-phase transactions
 }
 
 func (c *sqlmock) open(options []func(*sqlmock) error) (*sql.DB, Sqlmock, error) {
@@ -96,10 +334,18 @@ func (c *sqlmock) open(options []func(*sqlmock) error) (*sql.DB, Sqlmock, error)
 	if err != nil {
 		return db, c, err
 	}
+	if err := c.configure(options); err != nil {
+		return db, c, err
+	}
+	return db, c, db.Ping()
+}
+
+// configure applies options and defaults to the mock
+func (c *sqlmock) configure(options []func(*sqlmock) error) error {
 	for _, option := range options {
 		err := option(c)
 		if err != nil {
-			return db, c, err
+			return err
 		}
 	}
 	if c.converter == nil {
@@ -108,7 +354,29 @@ func (c *sqlmock) open(options []func(*sqlmock) error) (*sql.DB, Sqlmock, error)
 	if c.queryMatcher == nil {
 		c.queryMatcher = QueryMatcherRegexp
 	}
-	return db, c, db.Ping()
+	c.queryMatcher = c.normalizing(c.queryMatcher)
+	return nil
+}
+
+// normalizing wraps matcher with the options which
+// normalize actual SQL before it is matched
+func (c *sqlmock) normalizing(matcher QueryMatcher) QueryMatcher {
+	if c.quoteAgnostic {
+		matcher = quoteAgnosticMatcher(matcher)
+	}
+	if c.trailingPredicate != nil {
+		matcher = trailingPredicateMatcher(matcher, c.trailingPredicate)
+	}
+	return matcher
+}
+
+// queryMatches checks whether the actual query matches the
+// expectation, by its compiled regexp if it was given one
+func (c *sqlmock) queryMatches(e *queryBasedExpectation, query string) error {
+	if e.expectRe == nil {
+		return c.queryMatcher.Match(e.expectSQL, query)
+	}
+	return c.normalizing(compiledMatcher{e.expectRe}).Match(e.expectSQL, query)
 }
 
 func (c *sqlmock) ExpectClose() *ExpectedClose {
@@ -125,11 +393,20 @@ func (c *sqlmock) MatchExpectationsInOrder(b bool) {
 // be called depending on the circumstances, but if it is called
 // there must be an *ExpectedClose expectation satisfied.
 // meets http://golang.org/pkg/database/sql/driver/#Conn interface
-func (c *sqlmock) Close() error {
+func (c *conn) Close() error {
 	c.drv.Lock()
 	defer c.drv.Unlock()
 
 	c.opened--
+	c.mu.Lock()
+	delete(c.listeners, c)
+	c.mu.Unlock()
+	if c.id <= c.poisoned || c.resetFailed || c.lost {
+		// a poisoned or lost connection, or one which failed to reset
+		// its session is discarded by the pool, which is going to open
+		// a new one, so the dsn must stay available
+		return nil
+	}
 	if c.opened == 0 {
 		delete(c.drv.conns, c.dsn)
 	}
@@ -169,6 +446,18 @@ func (c *sqlmock) Close() error {
 }
 
 func (c *sqlmock) ExpectationsWereMet() error {
+	if err := c.cursorsWereMet(); err != nil {
+		return err
+	}
+	if err := c.resetsWereMet(); err != nil {
+		return err
+	}
+	if err := c.handshakesWereMet(); err != nil {
+		return err
+	}
+	if err := c.globalTxsResolved(); err != nil {
+		return err
+	}
 	for _, e := range c.expected {
 		e.Lock()
 		fulfilled := e.fulfilled()
@@ -180,15 +469,15 @@ func (c *sqlmock) ExpectationsWereMet() error {
 
 		// for expected prepared statement check whether it was closed if expected
 		if prep, ok := e.(*ExpectedPrepare); ok {
-			if prep.mustBeClosed && !prep.wasClosed {
-				return fmt.Errorf("expected prepared statement to be closed, but it was not: %s", prep)
+			if err := prep.closesWereMet(); err != nil {
+				return err
 			}
 		}
 
 		// must check whether all expected queried rows are closed
 		if query, ok := e.(*ExpectedQuery); ok {
-			if query.rowsMustBeClosed && !query.rowsWereClosed {
-				return fmt.Errorf("expected query rows to be closed, but it was not: %s", query)
+			if err := query.rowsWereMet(); err != nil {
+				return err
 			}
 		}
 	}
@@ -196,19 +485,28 @@ func (c *sqlmock) ExpectationsWereMet() error {
 }
 
 // Begin meets http://golang.org/pkg/database/sql/driver/#Conn interface
-func (c *sqlmock) Begin() (driver.Tx, error) {
+func (c *conn) Begin() (driver.Tx, error) {
 	ex, err := c.begin()
 	if ex != nil {
-		time.Sleep(ex.delay)
+		time.Sleep(c.delay(ex.delay))
 	}
 	if err != nil {
 		return nil, err
 	}
 
+	c.beginTx(ex, false)
 	return c, nil
 }
 
-func (c *sqlmock) begin() (*ExpectedBegin, error) {
+func (c *conn) begin() (*ExpectedBegin, error) {
+	c.takeSkipped()
+	if c.bad() {
+		return nil, driver.ErrBadConn
+	}
+	if err := c.beforeHandshake("database transaction Begin"); err != nil {
+		return nil, err
+	}
+
 	var expected *ExpectedBegin
 	var ok bool
 	var fulfilled int
@@ -250,31 +548,62 @@ func (c *sqlmock) ExpectBegin() *ExpectedBegin {
 }
 
 // Exec meets http://golang.org/pkg/database/sql/driver/#Execer
-func (c *sqlmock) Exec(query string, args []driver.Value) (driver.Result, error) {
-	namedArgs := make([]namedValue, len(args))
-	for i, v := range args {
-		namedArgs[i] = namedValue{
-			Ordinal: i + 1,
-			Value:   v,
-		}
-	}
-
-	ex, err := c.exec(query, namedArgs)
+func (c *conn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	namedArgs := ordinalValues(args)
+	ex, res, err := c.exec(nil, query, namedArgs)
 	if ex != nil {
-		time.Sleep(ex.delay)
+		start := time.Now()
+		time.Sleep(c.statementDelay(ex.delay))
+		ex.delayed(start)
 	}
 	if err != nil {
 		return nil, err
 	}
 
-	return ex.result, nil
+	return res, nil
 }
 
-func (c *sqlmock) exec(query string, args []namedValue) (*ExpectedExec, error) {
+func (c *conn) exec(stmt *statement, query string, args []namedValue) (*ExpectedExec, driver.Result, error) {
+	stmt.inputPassed()
+	c.takeSkipped()
+	if c.bad() {
+		return nil, nil, driver.ErrBadConn
+	}
+	if c.aborted {
+		return nil, nil, c.abortTxErr
+	}
+	if stmt == nil || !stmt.ex.fallback {
+		c.bind(args) // arguments were bound by the skipped call already
+	}
+
+	if err := c.placeholdersMatch(query, args); err != nil {
+		return nil, nil, fmt.Errorf("ExecQuery: %v", err)
+	}
+	if err := c.writeAllowed(query); err != nil {
+		return nil, nil, fmt.Errorf("ExecQuery '%s', %s", query, err)
+	}
+	if c.pendingHandshake() == nil {
+		if ok, err := c.cursorExec(query); ok {
+			if err != nil {
+				return nil, nil, fmt.Errorf("ExecQuery '%s', %s", query, err)
+			}
+			return &ExpectedExec{}, NewResult(0, 0), nil
+		}
+		if ok, err := c.twoPhaseExec(query); ok {
+			if err != nil {
+				return nil, nil, err
+			}
+			return &ExpectedExec{}, NewResult(0, 0), nil
+		}
+	}
+	call := c.call(CallExec, query, args)
+	head, trailing := c.trailingArgs(query, args)
+
 	var expected *ExpectedExec
 	var fulfilled int
 	var ok bool
-	for _, next := range c.expected {
+	expectations := c.expectations()
+	for _, next := range expectations {
 		next.Lock()
 		if next.fulfilled() {
 			next.Unlock()
@@ -287,15 +616,20 @@ func (c *sqlmock) exec(query string, args []namedValue) (*ExpectedExec, error) {
 				break
 			}
 			next.Unlock()
-			return nil, fmt.Errorf("call to ExecQuery '%s' with args %+v, was not expected, next expectation is: %s", query, args, next)
+			return nil, nil, fmt.Errorf("call to ExecQuery '%s' with args %+v, was not expected, next expectation is: %s", query, args, next)
 		}
 		if exec, ok := next.(*ExpectedExec); ok {
-			if err := c.queryMatcher.Match(exec.expectSQL, query); err != nil {
+			if err := c.queryMatches(&exec.queryBasedExpectation, query); err != nil {
+				next.Unlock()
+				continue
+			}
+
+			if err := exec.stmtMatches(stmt); err != nil {
 				next.Unlock()
 				continue
 			}
 
-			if err := exec.attemptArgMatch(args); err == nil {
+			if err := exec.attemptArgMatch(head); err == nil && exec.trailingArgsMatch(trailing) == nil && exec.callMatches(call) == nil {
 				expected = exec
 				break
 			}
@@ -304,31 +638,124 @@ func (c *sqlmock) exec(query string, args []namedValue) (*ExpectedExec, error) {
 	}
 	if expected == nil {
 		msg := "call to ExecQuery '%s' with args %+v was not expected"
-		if fulfilled == len(c.expected) {
+		if fulfilled == len(expectations) {
 			msg = "all expectations were already fulfilled, " + msg
 		}
-		return nil, fmt.Errorf(msg, query, args)
+		return nil, nil, fmt.Errorf(msg, query, args)
 	}
+	var recorded *Call
+	defer func() { c.matched(recorded) }()
 	defer expected.Unlock()
 
-	if err := c.queryMatcher.Match(expected.expectSQL, query); err != nil {
-		return nil, fmt.Errorf("ExecQuery: %v", err)
+	if err := c.queryMatches(&expected.queryBasedExpectation, query); err != nil {
+		return nil, nil, fmt.Errorf("ExecQuery: %v", err)
 	}
 
-	if err := expected.argsMatches(args); err != nil {
-		return nil, fmt.Errorf("ExecQuery '%s', arguments do not match: %s", query, err)
+	if err := expected.stmtMatches(stmt); err != nil {
+		return nil, nil, fmt.Errorf("ExecQuery '%s', %s", query, err)
 	}
 
-	expected.triggered = true
-	if expected.err != nil {
-		return expected, expected.err // mocked to return error
+	if err := expected.argsMatches(head); err != nil {
+		return nil, nil, fmt.Errorf("ExecQuery '%s', arguments do not match: %s", query, err)
+	}
+
+	if err := expected.trailingArgsMatch(trailing); err != nil {
+		return nil, nil, fmt.Errorf("ExecQuery '%s', trailing predicate arguments do not match: %s", query, err)
+	}
+
+	if err := expected.callMatches(call); err != nil {
+		return nil, nil, fmt.Errorf("ExecQuery '%s', %s", query, err)
+	}
+
+	if err := c.tablesAllowed(&expected.queryBasedExpectation, query); err != nil {
+		return nil, nil, fmt.Errorf("ExecQuery '%s', %s", query, err)
+	}
+
+	if stmt != nil {
+		if err := stmt.constantArgsMatch(args); err != nil {
+			return nil, nil, fmt.Errorf("ExecQuery '%s', %s", query, err)
+		}
+	}
+
+	if err := c.phaseReached(expected); err != nil {
+		return nil, nil, fmt.Errorf("ExecQuery '%s', %s", query, err)
+	}
+
+	if stmt == nil && expected.skipFastPath {
+		c.skipped = query
+		return nil, nil, driver.ErrSkip
+	}
+
+	if expected.errorsExhausted() {
+		return nil, nil, fmt.Errorf("ExecQuery '%s' with args %+v, was called %d times, but only %d errors were set in sequence for expectation %T as %+v", query, args, expected.calls+1, len(expected.errs), expected, expected)
+	}
+	err := expected.callError()
+	if err == nil {
+		err = c.timedOut(expected.delay)
+	}
+
+	res := expected.result
+	if len(expected.results) > 0 && err == nil {
+		if expected.calls >= len(expected.results) {
+			return nil, nil, fmt.Errorf("ExecQuery '%s' with args %+v, was called %d times, but only %d results were set in sequence for expectation %T as %+v", query, args, expected.calls+1, len(expected.results), expected, expected)
+		}
+		res = expected.results[expected.calls]
+	}
+
+	if expected.resultFunc != nil && err == nil {
+		// a failed result func leaves the expectation unfulfilled
+		if res, err = expected.resultFunc(query, args); err != nil {
+			return expected, nil, err
+		}
+	}
+
+	expected.trigger()
+	call.ex = expected
+	call.Sensitive = expected.sensitive
+	c.record(call)
+	recorded = call
+	if stmt != nil {
+		stmt.executed()
+	}
+	if err != nil {
+		c.badConnReturned(err)
+		return expected, nil, err // mocked to return error
+	}
+
+	if expected.insertInto != "" {
+		c.insert(expected.insertInto, call.Args)
+		if res == nil {
+			res = NewResult(0, 1)
+		}
+	}
+
+	if expected.insertID != nil {
+		if res == nil {
+			res = NewResult(0, 1)
+		}
+		res = &insertIDResult{Result: res, insertID: expected.insertID(args)}
+	}
+
+	if expected.autoIncr {
+		if res == nil {
+			res = NewResult(0, 1)
+		}
+		res = c.autoIncrement(res)
+	}
+
+	if res == nil {
+		return nil, nil, fmt.Errorf("ExecQuery '%s' with args %+v, must return a database/sql/driver.Result, but it was not set for expectation %T as %+v", query, args, expected, expected)
 	}
 
-	if expected.result == nil {
-		return nil, fmt.Errorf("ExecQuery '%s' with args %+v, must return a database/sql/driver.Result, but it was not set for expectation %T as %+v", query, args, expected, expected)
+	if expected.warnings != nil {
+		expected.warned++
+		res = &warningsResult{Result: res, warnings: &rowSets{sets: []*Rows{expected.warnings}, ex: &ExpectedQuery{}}}
 	}
 
-	return expected, nil
+	c.listen(query)
+	c.savepoint(query)
+	c.setTimeout(query)
+	return expected, res, nil
 }
 
 func (c *sqlmock) ExpectExec(expectedSQL string) *ExpectedExec {
@@ -339,20 +766,36 @@ func (c *sqlmock) ExpectExec(expectedSQL string) *ExpectedExec {
 	return e
 }
 
+func (c *sqlmock) ExpectExecRegexp(re *regexp.Regexp) *ExpectedExec {
+	e := c.ExpectExec(re.String())
+	e.expectRe = re
+	return e
+}
+
 // Prepare meets http://golang.org/pkg/database/sql/driver/#Conn interface
-func (c *sqlmock) Prepare(query string) (driver.Stmt, error) {
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
 	ex, err := c.prepare(query)
 	if ex != nil {
-		time.Sleep(ex.delay)
+		time.Sleep(c.delay(ex.delay))
 	}
 	if err != nil {
 		return nil, err
 	}
 
-	return &statement{c, ex, query}, nil
+	return c.newStatement(ex, query), nil
 }
 
-func (c *sqlmock) prepare(query string) (*ExpectedPrepare, error) {
+func (c *conn) prepare(query string) (*ExpectedPrepare, error) {
+	if c.bad() {
+		return nil, driver.ErrBadConn
+	}
+	if err := c.beforeHandshake(fmt.Sprintf("Prepare statement with query '%s'", query)); err != nil {
+		return nil, err
+	}
+	if ex := c.skippedPrepare(query); ex != nil {
+		return ex, nil
+	}
+
 	var expected *ExpectedPrepare
 	var fulfilled int
 	var ok bool
@@ -371,6 +814,9 @@ func (c *sqlmock) prepare(query string) (*ExpectedPrepare, error) {
 			}
 
 			next.Unlock()
+			if ex := c.rePrepared(query); ex != nil {
+				return ex, nil
+			}
 			return nil, fmt.Errorf("call to Prepare statement with query '%s', was not expected, next expectation is: %s", query, next)
 		}
 
@@ -384,18 +830,30 @@ func (c *sqlmock) prepare(query string) (*ExpectedPrepare, error) {
 	}
 
 	if expected == nil {
+		if ex := c.rePrepared(query); ex != nil {
+			return ex, nil
+		}
 		msg := "call to Prepare '%s' query was not expected"
 		if fulfilled == len(c.expected) {
 			msg = "all expectations were already fulfilled, " + msg
 		}
 		return nil, fmt.Errorf(msg, query)
 	}
-	defer expected.Unlock()
 	if err := c.queryMatcher.Match(expected.expectSQL, query); err != nil {
+		expected.Unlock()
+		if ex := c.rePrepared(query); ex != nil {
+			return ex, nil
+		}
 		return nil, fmt.Errorf("Prepare: %v", err)
 	}
+	defer expected.Unlock()
 
 	expected.triggered = true
+	if expected.err == nil {
+		expected.conns = append(expected.conns, c)
+		c.txStatement(query, func(s *TxStatementStats) { s.Prepares++ })
+		c.prepared(query, false)
+	}
 	return expected, expected.err
 }
 
@@ -411,8 +869,147 @@ type namedValue struct {
 	Value   driver.Value
 }
 
-// Query meets http://golang.org/pkg/database/sql/driver/#Queryer
-func (c *sqlmock) Query(query string, args []driver.Value) (driver.Rows, error) {
+// placeholdersMatch verifies, if strict placeholder arity is enabled,
+// that the number of placeholders in query equals the number of args
+func (c *sqlmock) placeholdersMatch(query string, args []namedValue) error {
+	if c.requireArgs && len(args) == 0 {
+		if n := countPlaceholders(query); n > 0 {
+			return fmt.Errorf("query '%s' has %d placeholders, but was called without arguments", query, n)
+		}
+	}
+	if !c.strictPlaceholders {
+		return nil
+	}
+	if n := countPlaceholders(query); n != len(args) {
+		return fmt.Errorf("query '%s' has %d placeholders, but %d arguments were bound", query, n, len(args))
+	}
+	return nil
+}
+
+// trailingArgs splits args into the ones bound to query and
+// the ones bound to the ignored trailing predicate, assuming
+// the predicate placeholders are bound last
+func (c *sqlmock) trailingArgs(query string, args []namedValue) (head, trailing []namedValue) {
+	if c.trailingPredicate == nil {
+		return args, nil
+	}
+	loc := c.trailingPredicate.FindStringIndex(query)
+	if loc == nil {
+		return args, nil
+	}
+	n := countPlaceholders(query[loc[0]:])
+	if n > len(args) {
+		n = len(args)
+	}
+	return args[:len(args)-n], args[len(args)-n:]
+}
+
+// tablesAllowed checks whether all tables referenced in query
+// are in the configured allowlist
+func (c *sqlmock) tablesAllowed(e *queryBasedExpectation, query string) error {
+	if c.tableAllowlist == nil || e.skipTableAllowlist {
+		return nil
+	}
+	for _, table := range referencedTables(query) {
+		name := strings.ToLower(table)
+		if c.tableAllowlist[name] {
+			continue
+		}
+		if i := strings.LastIndex(name, "."); i >= 0 && c.tableAllowlist[name[i+1:]] {
+			continue
+		}
+		return fmt.Errorf("references table '%s', which is not in the table allowlist", table)
+	}
+	return nil
+}
+
+// rowsMatch checks the rows to be returned for query
+// against the column checks enabled by options
+func (c *sqlmock) rowsMatch(query string, rows driver.Rows) error {
+	if rs, ok := rows.(*rowSets); ok && len(rs.sets) == 0 {
+		return nil // no rows to declare columns
+	}
+	if err := c.columnsMatch(query, rows); err != nil {
+		return err
+	}
+	return c.selectListMatches(query, rows)
+}
+
+// columnsMatch checks whether the columns selected by query are
+// in the same order as the columns of the rows to be returned
+func (c *sqlmock) columnsMatch(query string, rows driver.Rows) error {
+	if !c.strictColumnOrder || rows == nil {
+		return nil
+	}
+	selected, ok := selectColumns(query)
+	if !ok {
+		return nil
+	}
+
+	declared := rows.Columns()
+	mismatch := len(selected) != len(declared)
+	for i := 0; !mismatch && i < len(selected); i++ {
+		mismatch = !strings.EqualFold(selected[i], declared[i])
+	}
+	if mismatch {
+		return fmt.Errorf("selected columns %v do not match the order of declared rows columns %v", selected, declared)
+	}
+	return nil
+}
+
+// selectListMatches checks whether the query names all the columns
+// of the rows to be returned, or selects as many columns as they
+// declare, depending on the enabled options
+func (c *sqlmock) selectListMatches(query string, rows driver.Rows) error {
+	if !c.allColumnsSelected && !c.columnCount || rows == nil {
+		return nil
+	}
+	items, ok := selectItems(query)
+	if !ok {
+		return nil
+	}
+	for _, item := range items {
+		if item == "*" || strings.HasSuffix(item, ".*") {
+			return nil
+		}
+	}
+
+	declared := rows.Columns()
+	if selected, ok := selectColumns(query); ok && c.allColumnsSelected {
+		var missing []string
+		for _, col := range declared {
+			found := false
+			for _, sel := range selected {
+				found = found || strings.EqualFold(sel, col)
+			}
+			if !found {
+				missing = append(missing, col)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("selected columns %v would not scan declared rows columns %v", selected, missing)
+		}
+	}
+	if c.columnCount && len(items) != len(declared) {
+		return fmt.Errorf("query selects %d columns, but rows declare %d columns %v", len(items), len(declared), declared)
+	}
+	return nil
+}
+
+// delay returns the duration to wait for the given
+// expectation delay, clamped to the configured maximum
+func (c *sqlmock) delay(d time.Duration) time.Duration {
+	if c.maxDelay <= 0 || d <= c.maxDelay {
+		return d
+	}
+	if c.logDelay != nil {
+		c.logDelay("sqlmock: delay of %s was clamped to %s", d, c.maxDelay)
+	}
+	return c.maxDelay
+}
+
+// ordinalValues converts driver values to ordinal named values
+func ordinalValues(args []driver.Value) []namedValue {
 	namedArgs := make([]namedValue, len(args))
 	for i, v := range args {
 		namedArgs[i] = namedValue{
@@ -420,23 +1017,61 @@ func (c *sqlmock) Query(query string, args []driver.Value) (driver.Rows, error)
 			Value:   v,
 		}
 	}
+	return namedArgs
+}
 
-	ex, err := c.query(query, namedArgs)
+// Query meets http://golang.org/pkg/database/sql/driver/#Queryer
+func (c *conn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	namedArgs := ordinalValues(args)
+	ex, rows, err := c.query(nil, query, namedArgs)
 	if ex != nil {
-		time.Sleep(ex.delay)
+		start := time.Now()
+		time.Sleep(c.statementDelay(ex.delay))
+		ex.delayed(start)
 	}
 	if err != nil {
 		return nil, err
 	}
 
-	return ex.rows, nil
+	return rows, nil
 }
 
-func (c *sqlmock) query(query string, args []namedValue) (*ExpectedQuery, error) {
+func (c *conn) query(stmt *statement, query string, args []namedValue) (*ExpectedQuery, driver.Rows, error) {
+	stmt.inputPassed()
+	c.takeSkipped()
+	if c.bad() {
+		return nil, nil, driver.ErrBadConn
+	}
+	if c.aborted {
+		return nil, nil, c.abortTxErr
+	}
+	if stmt == nil || !stmt.ex.fallback {
+		c.bind(args) // arguments were bound by the skipped call already
+	}
+
+	if err := c.placeholdersMatch(query, args); err != nil {
+		return nil, nil, fmt.Errorf("Query: %v", err)
+	}
+	if err := c.writeAllowed(query); err != nil {
+		return nil, nil, fmt.Errorf("Query '%s', %s", query, err)
+	}
+	if c.pendingHandshake() == nil {
+		if r, ok, err := c.cursorFetch(query); ok {
+			if err != nil {
+				return nil, nil, fmt.Errorf("Query '%s', %s", query, err)
+			}
+			ex := &ExpectedQuery{}
+			return ex, &rowSets{sets: []*Rows{r}, ex: ex}, nil
+		}
+	}
+	call := c.call(CallQuery, query, args)
+	head, trailing := c.trailingArgs(query, args)
+
 	var expected *ExpectedQuery
 	var fulfilled int
 	var ok bool
-	for _, next := range c.expected {
+	expectations := c.expectations()
+	for _, next := range expectations {
 		next.Lock()
 		if next.fulfilled() {
 			next.Unlock()
@@ -449,14 +1084,18 @@ func (c *sqlmock) query(query string, args []namedValue) (*ExpectedQuery, error)
 				break
 			}
 			next.Unlock()
-			return nil, fmt.Errorf("call to Query '%s' with args %+v, was not expected, next expectation is: %s", query, args, next)
+			return nil, nil, fmt.Errorf("call to Query '%s' with args %+v, was not expected, next expectation is: %s", query, args, next)
 		}
 		if qr, ok := next.(*ExpectedQuery); ok {
-			if err := c.queryMatcher.Match(qr.expectSQL, query); err != nil {
+			if err := c.queryMatches(&qr.queryBasedExpectation, query); err != nil {
+				next.Unlock()
+				continue
+			}
+			if err := qr.stmtMatches(stmt); err != nil {
 				next.Unlock()
 				continue
 			}
-			if err := qr.attemptArgMatch(args); err == nil {
+			if err := qr.attemptArgMatch(head); err == nil && qr.trailingArgsMatch(trailing) == nil && qr.callMatches(call) == nil {
 				expected = qr
 				break
 			}
@@ -466,31 +1105,128 @@ func (c *sqlmock) query(query string, args []namedValue) (*ExpectedQuery, error)
 
 	if expected == nil {
 		msg := "call to Query '%s' with args %+v was not expected"
-		if fulfilled == len(c.expected) {
+		if fulfilled == len(expectations) {
 			msg = "all expectations were already fulfilled, " + msg
 		}
-		return nil, fmt.Errorf(msg, query, args)
+		return nil, nil, fmt.Errorf(msg, query, args)
 	}
 
+	var recorded *Call
+	defer func() { c.matched(recorded) }()
 	defer expected.Unlock()
 
-	if err := c.queryMatcher.Match(expected.expectSQL, query); err != nil {
-		return nil, fmt.Errorf("Query: %v", err)
+	if err := c.queryMatches(&expected.queryBasedExpectation, query); err != nil {
+		return nil, nil, fmt.Errorf("Query: %v", err)
 	}
 
-	if err := expected.argsMatches(args); err != nil {
-		return nil, fmt.Errorf("Query '%s', arguments do not match: %s", query, err)
+	if err := expected.stmtMatches(stmt); err != nil {
+		return nil, nil, fmt.Errorf("Query '%s', %s", query, err)
 	}
 
-	expected.triggered = true
-	if expected.err != nil {
-		return expected, expected.err // mocked to return error
+	if err := expected.argsMatches(head); err != nil {
+		return nil, nil, fmt.Errorf("Query '%s', arguments do not match: %s", query, err)
+	}
+
+	if err := expected.trailingArgsMatch(trailing); err != nil {
+		return nil, nil, fmt.Errorf("Query '%s', trailing predicate arguments do not match: %s", query, err)
+	}
+
+	if err := expected.callMatches(call); err != nil {
+		return nil, nil, fmt.Errorf("Query '%s', %s", query, err)
+	}
+
+	if err := c.tablesAllowed(&expected.queryBasedExpectation, query); err != nil {
+		return nil, nil, fmt.Errorf("Query '%s', %s", query, err)
+	}
+
+	if err := c.rowsMatch(query, expected.rows); err != nil {
+		return nil, nil, fmt.Errorf("Query '%s', %s", query, err)
+	}
+
+	if stmt != nil {
+		if err := stmt.constantArgsMatch(args); err != nil {
+			return nil, nil, fmt.Errorf("Query '%s', %s", query, err)
+		}
+	}
+
+	if err := c.phaseReached(expected); err != nil {
+		return nil, nil, fmt.Errorf("Query '%s', %s", query, err)
+	}
+
+	if stmt == nil && expected.skipFastPath {
+		c.skipped = query
+		return nil, nil, driver.ErrSkip
+	}
+
+	if expected.errorsExhausted() {
+		return nil, nil, fmt.Errorf("Query '%s' with args %+v, was called %d times, but only %d errors were set in sequence for expectation %T as %+v", query, args, expected.calls+1, len(expected.errs), expected, expected)
+	}
+	err := expected.callError()
+	if err == nil {
+		err = c.timedOut(expected.delay)
+	}
+
+	var built *Rows
+	if expected.rowsFunc != nil && err == nil {
+		// a failed rows func leaves the expectation unfulfilled
+		if built, err = expected.rowsFunc(query, args); err != nil {
+			return expected, nil, err
+		}
+		if built == nil {
+			return nil, nil, fmt.Errorf("Query '%s' with args %+v, rows func must return *sqlmock.Rows, but returned nil for expectation %T as %+v", query, args, expected, expected)
+		}
+		if err := c.rowsMatch(query, &rowSets{sets: []*Rows{built}}); err != nil {
+			return nil, nil, fmt.Errorf("Query '%s', %s", query, err)
+		}
+	} else if len(expected.rowsSeq) > 0 && err == nil {
+		built = expected.rowsSeq[len(expected.rowsSeq)-1]
+		if expected.calls < len(expected.rowsSeq) {
+			built = expected.rowsSeq[expected.calls]
+		}
+		if err := c.rowsMatch(query, &rowSets{sets: []*Rows{built}}); err != nil {
+			return nil, nil, fmt.Errorf("Query '%s', %s", query, err)
+		}
+	}
+
+	expected.trigger()
+	call.ex = expected
+	call.Sensitive = expected.sensitive
+	c.record(call)
+	recorded = call
+	if stmt != nil {
+		stmt.executed()
+	}
+	if err != nil {
+		c.badConnReturned(err)
+		return expected, nil, err // mocked to return error
+	}
+
+	if expected.storedTable != "" {
+		r, err := c.stored(expected.storedTable, expected.storedColumns)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Query '%s', %s", query, err)
+		}
+		return expected, &rowSets{sets: []*Rows{r}, ex: expected}, nil
+	}
+
+	if built != nil {
+		if built.colsErr != nil {
+			return expected, nil, built.colsErr
+		}
+		return expected, &rowSets{sets: []*Rows{built}, ex: expected}, nil
 	}
 
 	if expected.rows == nil {
-		return nil, fmt.Errorf("Query '%s' with args %+v, must return a database/sql/driver.Rows, but it was not set for expectation %T as %+v", query, args, expected, expected)
+		return nil, nil, fmt.Errorf("Query '%s' with args %+v, must return a database/sql/driver.Rows, but it was not set for expectation %T as %+v", query, args, expected, expected)
 	}
-	return expected, nil
+	rows := expected.rows
+	if rs, ok := rows.(*rowSets); ok {
+		if len(rs.sets) > 0 && rs.sets[0].colsErr != nil {
+			return expected, nil, rs.sets[0].colsErr
+		}
+		rows = &rowSets{sets: rs.sets, ex: expected} // fresh cursor for every call
+	}
+	return expected, rows, nil
 }
 
 func (c *sqlmock) ExpectQuery(expectedSQL string) *ExpectedQuery {
@@ -501,6 +1237,12 @@ func (c *sqlmock) ExpectQuery(expectedSQL string) *ExpectedQuery {
 	return e
 }
 
+func (c *sqlmock) ExpectQueryRegexp(re *regexp.Regexp) *ExpectedQuery {
+	e := c.ExpectQuery(re.String())
+	e.expectRe = re
+	return e
+}
+
 func (c *sqlmock) ExpectCommit() *ExpectedCommit {
 	e := &ExpectedCommit{}
 	c.expected = append(c.expected, e)
@@ -526,7 +1268,17 @@ func (c *sqlmock) ExpectPing() *ExpectedPing {
 }
 
 // Commit meets http://golang.org/pkg/database/sql/driver/#Tx
-func (c *sqlmock) Commit() error {
+func (c *conn) Commit() (err error) {
+	c.takeSkipped()
+	tx := c.tx
+	defer func() {
+		c.endStore(tx, err == nil)
+		c.txEvent(TxCommit, tx, err)
+	}()
+	if c.endTx() {
+		return c.abortTxErr // aborted transaction cannot be committed
+	}
+
 	var expected *ExpectedCommit
 	var fulfilled int
 	var ok bool
@@ -539,7 +1291,15 @@ func (c *sqlmock) Commit() error {
 		}
 
 		if expected, ok = next.(*ExpectedCommit); ok {
-			break
+			err := c.stepsDone(expected, tx)
+			if err == nil {
+				break
+			}
+			expected = nil
+			if c.ordered {
+				next.Unlock()
+				return fmt.Errorf("call to Commit transaction, %s", err)
+			}
 		}
 
 		next.Unlock()
@@ -561,7 +1321,15 @@ func (c *sqlmock) Commit() error {
 }
 
 // Rollback meets http://golang.org/pkg/database/sql/driver/#Tx
-func (c *sqlmock) Rollback() error {
+func (c *conn) Rollback() (err error) {
+	c.takeSkipped()
+	tx := c.tx
+	defer func() {
+		c.endStore(tx, false)
+		c.txEvent(TxRollback, tx, err)
+	}()
+	c.endTx()
+
 	var expected *ExpectedRollback
 	var fulfilled int
 	var ok bool
@@ -604,6 +1372,12 @@ func (c *sqlmock) NewRows(columns []string) *Rows {
 	return r
 }
 
+func (c *sqlmock) NewRowsWithColumnDefinition(columns ...*Column) *Rows {
+	r := NewRowsWithColumnDefinition(columns...)
+	r.converter = c.converter
+	return r
+}
+
 // //Ping meets https://golang.org/pkg/database/sql/driver/#Pinger
 // func (c *sqlmock) Ping(ctx context.Context) error {
 // 	fmt.Println("Inside Ping!!!!!")
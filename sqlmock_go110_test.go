@@ -0,0 +1,73 @@
+// +build go1.10
+
+package sqlmock
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConnectionReuseCount(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	db.SetMaxIdleConns(1)
+
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(NewRows([]string{"id"}).AddRow(1))
+	}
+	for i := 0; i < 2; i++ {
+		rows, err := db.Query("SELECT id FROM users")
+		if err != nil {
+			t.Fatalf("error '%s' was not expected, while selecting users", err)
+		}
+		rows.Close()
+	}
+
+	if n := mock.ConnectionReuseCount(); n != 2 {
+		t.Errorf("expected an idle connection to be reused, twice, but it was reused %d times", n)
+	}
+	if n := mock.MaxConcurrentConnections(); n != 1 {
+		t.Errorf("expected a single connection to be opened, but got %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestExpectResetSessionError(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	db.SetMaxIdleConns(1)
+
+	mock.ExpectResetSession().WillReturnError(errors.New("session could not be reset"))
+	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(NewRows([]string{"id"}).AddRow(1))
+
+	rows, err := db.Query("SELECT id FROM users")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, since the pool opens a new connection", err)
+	}
+	rows.Close()
+
+	if n := mock.MaxConcurrentConnections(); n != 1 {
+		t.Errorf("expected the failed connection to be closed before a new one was opened, but got %d open", n)
+	}
+	if n := mock.ConnectionReuseCount(); n != 0 {
+		t.Errorf("expected the failed connection not to be reused, but got %d reuses", n)
+	}
+	if calls := mock.Calls(); len(calls) != 1 || calls[0].Conn != 2 {
+		t.Errorf("expected the query to run on a new connection, but got %+v", calls)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"database/sql/driver"
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -13,23 +14,44 @@ import (
 // such cancellation error.
 var ErrCancelled = errors.New("canceling query due to user request")
 
+// errNamedArgsRejected mimics the error returned by database/sql
+// for drivers, which do not support named parameters.
+var errNamedArgsRejected = errors.New("sql: driver does not support the use of Named Parameters")
+
+// givenContext reports whether ctx was given by the caller,
+// rather than defaulted by database/sql for calls without one
+func givenContext(ctx context.Context) bool {
+	return ctx != context.Background() && ctx != context.TODO()
+}
+
 // Implement the "QueryerContext" interface
-func (c *sqlmock) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
 	namedArgs := make([]namedValue, len(args))
 	for i, nv := range args {
 		namedArgs[i] = namedValue(nv)
 	}
 
-	ex, err := c.query(query, namedArgs)
+	c.withContext = givenContext(ctx)
+	ex, rows, err := c.query(nil, query, namedArgs)
+	c.withContext = false
 	if ex != nil {
+		start := time.Now()
 		select {
-		case <-time.After(ex.delay):
+		case <-time.After(c.statementDelay(ex.delay)):
+			ex.delayed(start)
 			if err != nil {
 				return nil, err
 			}
-			return ex.rows, nil
+			if rs, ok := rows.(*rowSets); ok {
+				rs.done, rs.closing = ctx.Done(), c.closing
+			}
+			return rows, nil
 		case <-ctx.Done():
-			return nil, ErrCancelled
+			ex.delayed(start)
+			return nil, c.cancelled()
+		case <-c.closing:
+			ex.delayed(start)
+			return nil, c.cancelled()
 		}
 	}
 
@@ -37,22 +59,30 @@ func (c *sqlmock) QueryContext(ctx context.Context, query string, args []driver.
 }
 
 // Implement the "ExecerContext" interface
-func (c *sqlmock) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
 	namedArgs := make([]namedValue, len(args))
 	for i, nv := range args {
 		namedArgs[i] = namedValue(nv)
 	}
 
-	ex, err := c.exec(query, namedArgs)
+	c.withContext = givenContext(ctx)
+	ex, res, err := c.exec(nil, query, namedArgs)
+	c.withContext = false
 	if ex != nil {
+		start := time.Now()
 		select {
-		case <-time.After(ex.delay):
+		case <-time.After(c.statementDelay(ex.delay)):
+			ex.delayed(start)
 			if err != nil {
 				return nil, err
 			}
-			return ex.result, nil
+			return res, nil
 		case <-ctx.Done():
-			return nil, ErrCancelled
+			ex.delayed(start)
+			return nil, c.cancelled()
+		case <-c.closing:
+			ex.delayed(start)
+			return nil, c.cancelled()
 		}
 	}
 
@@ -60,17 +90,23 @@ func (c *sqlmock) ExecContext(ctx context.Context, query string, args []driver.N
 }
 
 // Implement the "ConnBeginTx" interface
-func (c *sqlmock) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
 	ex, err := c.begin()
 	if ex != nil {
 		select {
-		case <-time.After(ex.delay):
+		case <-time.After(c.delay(ex.delay)):
 			if err != nil {
 				return nil, err
 			}
+			if ex.readOnly && !opts.ReadOnly {
+				return nil, fmt.Errorf("call to database transaction Begin was expected to start a read-only transaction")
+			}
+			c.beginTx(ex, opts.ReadOnly)
 			return c, nil
 		case <-ctx.Done():
 			return nil, ErrCancelled
+		case <-c.closing:
+			return nil, ErrCancelled
 		}
 	}
 
@@ -78,17 +114,19 @@ func (c *sqlmock) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx
 }
 
 // Implement the "ConnPrepareContext" interface
-func (c *sqlmock) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
 	ex, err := c.prepare(query)
 	if ex != nil {
 		select {
-		case <-time.After(ex.delay):
+		case <-time.After(c.delay(ex.delay)):
 			if err != nil {
 				return nil, err
 			}
-			return &statement{c, ex, query}, nil
+			return c.newStatement(ex, query), nil
 		case <-ctx.Done():
 			return nil, ErrCancelled
+		case <-c.closing:
+			return nil, ErrCancelled
 		}
 	}
 
@@ -98,7 +136,14 @@ func (c *sqlmock) PrepareContext(ctx context.Context, query string) (driver.Stmt
 // Implement the "Pinger" interface
 // for now we do not have a Ping expectation
 // may be something for the future
-func (c *sqlmock) Ping(ctx context.Context) error {
+func (c *conn) Ping(ctx context.Context) error {
+	c.takeSkipped()
+	if c.bad() {
+		return driver.ErrBadConn
+	}
+	if err := c.beforeHandshake("database Ping"); err != nil {
+		return err
+	}
 	for _, expect := range c.expected {
 		if e, ok := expect.(*ExpectedPing); ok {
 			return e.err
@@ -107,14 +152,132 @@ func (c *sqlmock) Ping(ctx context.Context) error {
 	return nil
 }
 
+// Implement the "SessionResetter" interface, database/sql
+// resets the session before an idle connection is reused
+func (c *conn) ResetSession(ctx context.Context) error {
+	c.takeSkipped()
+	if err := c.resetSession(); err != nil {
+		return err
+	}
+	c.drv.Lock()
+	c.reused++
+	c.drv.Unlock()
+	return nil
+}
+
+// NotificationWaiter is implemented by sqlmock driver connections in
+// order to simulate asynchronous notifications, like Postgres LISTEN
+// and NOTIFY. The driver connection may be accessed with sql.Conn.Raw:
+//
+//	err := conn.Raw(func(dc interface{}) error {
+//		n, err := dc.(sqlmock.NotificationWaiter).WaitForNotification(ctx)
+//		...
+//	})
+//
+// Notifications are delivered by Sqlmock.Notify.
+type NotificationWaiter interface {
+	// WaitForNotification blocks until a notification is delivered
+	// to a channel the connection is listening on, or ctx is done.
+	WaitForNotification(ctx context.Context) (*Notification, error)
+}
+
+// WaitForNotification implements NotificationWaiter
+func (c *conn) WaitForNotification(ctx context.Context) (*Notification, error) {
+	c.takeSkipped()
+	c.waited()
+	for {
+		c.mu.Lock()
+		l := c.listener()
+		if len(l.queue) > 0 {
+			n := l.queue[0]
+			l.queue = l.queue[1:]
+			c.mu.Unlock()
+			return n, nil
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-l.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.closing:
+			return nil, ErrCancelled
+		}
+	}
+}
+
 // Implement the "StmtExecContext" interface
 func (stmt *statement) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
-	return stmt.conn.ExecContext(ctx, stmt.query, args)
+	namedArgs := make([]namedValue, len(args))
+	for i, nv := range args {
+		namedArgs[i] = namedValue(nv)
+	}
+
+	stmt.conn.withContext = givenContext(ctx)
+	ex, res, err := stmt.conn.exec(stmt, stmt.query, namedArgs)
+	stmt.conn.withContext = false
+	if ex != nil {
+		start := time.Now()
+		select {
+		case <-time.After(stmt.conn.statementDelay(ex.delay)):
+			ex.delayed(start)
+			if err != nil {
+				return nil, err
+			}
+			return res, nil
+		case <-ctx.Done():
+			ex.delayed(start)
+			return nil, stmt.conn.cancelled()
+		case <-stmt.conn.closing:
+			ex.delayed(start)
+			return nil, stmt.conn.cancelled()
+		}
+	}
+
+	return nil, err
 }
 
 // Implement the "StmtQueryContext" interface
 func (stmt *statement) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
-	return stmt.conn.QueryContext(ctx, stmt.query, args)
+	namedArgs := make([]namedValue, len(args))
+	for i, nv := range args {
+		namedArgs[i] = namedValue(nv)
+	}
+
+	stmt.conn.withContext = givenContext(ctx)
+	ex, rows, err := stmt.conn.query(stmt, stmt.query, namedArgs)
+	stmt.conn.withContext = false
+	if ex != nil {
+		start := time.Now()
+		select {
+		case <-time.After(stmt.conn.statementDelay(ex.delay)):
+			ex.delayed(start)
+			if err != nil {
+				return nil, err
+			}
+			if rs, ok := rows.(*rowSets); ok {
+				rs.done, rs.closing = ctx.Done(), stmt.conn.closing
+			}
+			return rows, nil
+		case <-ctx.Done():
+			ex.delayed(start)
+			return nil, stmt.conn.cancelled()
+		case <-stmt.conn.closing:
+			ex.delayed(start)
+			return nil, stmt.conn.cancelled()
+		}
+	}
+
+	return nil, err
 }
 
 // @TODO maybe add ExpectedBegin.WithOptions(driver.TxOptions)
+
+// cancelled aborts the current transaction, if the connection
+// is configured to do so, and returns the cancellation error
+func (c *conn) cancelled() error {
+	if c.inTx && c.abortTxErr != nil {
+		c.aborted = true
+	}
+	return ErrCancelled
+}
@@ -6,6 +6,18 @@ import "database/sql/driver"
 
 // CheckNamedValue meets https://golang.org/pkg/database/sql/driver/#NamedValueChecker
 func (c *sqlmock) CheckNamedValue(nv *driver.NamedValue) (err error) {
+	if nv.Name != "" && c.rejectNamedArgs {
+		return errNamedArgsRejected
+	}
+	if c.skipValueCheck {
+		return driver.ErrSkip
+	}
 	nv.Value, err = c.converter.ConvertValue(nv.Value)
 	return err
 }
+
+// CheckNamedValue meets https://golang.org/pkg/database/sql/driver/#NamedValueChecker
+// shards share the options, so the first one converts values for all
+func (c *shardConn) CheckNamedValue(nv *driver.NamedValue) error {
+	return c.conns[0].CheckNamedValue(nv)
+}
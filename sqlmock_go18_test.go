@@ -5,7 +5,10 @@ package sqlmock
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -474,3 +477,791 @@ func TestContextExecErrorDelay(t *testing.T) {
 		t.Errorf("expecting a delay of less than %v before error, actual delay was %v", delay, elapsed)
 	}
 }
+
+func TestPreparedStatementContextExecWithArgs(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectPrepare("UPDATE articles SET title = \\? WHERE id = \\?").
+		ExpectExec().
+		WithArgs("hello", 5).
+		WillReturnResult(NewResult(0, 1))
+
+	ctx := context.Background()
+	stmt, err := db.PrepareContext(ctx, "UPDATE articles SET title = ? WHERE id = ?")
+	if err != nil {
+		t.Fatalf("error was not expected, but got: %v", err)
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, "hello", 4); err == nil {
+		t.Error("error was expected, since exec arguments do not match")
+	}
+
+	res, err := stmt.ExecContext(ctx, "hello", 5)
+	if err != nil {
+		t.Fatalf("error was not expected, but got: %v", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil || affected != 1 {
+		t.Errorf("expected 1 affected row without error, but got: %d, %v", affected, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPreparedStatementExecLinkedToStatement(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectPrepare("UPDATE articles SET body")
+	mock.ExpectPrepare("UPDATE articles").
+		ExpectExec().
+		WithArgs("hello", 5).
+		WillReturnResult(NewResult(0, 1))
+
+	ctx := context.Background()
+	other, err := db.PrepareContext(ctx, "UPDATE articles SET body = ? WHERE id = ?")
+	if err != nil {
+		t.Fatalf("error was not expected, but got: %v", err)
+	}
+	defer other.Close()
+	if _, err := other.ExecContext(ctx, "hello", 5); err == nil {
+		t.Error("error was expected, since exec was made on a statement prepared for another expectation")
+	}
+
+	stmt, err := db.PrepareContext(ctx, "UPDATE articles SET title = ? WHERE id = ?")
+	if err != nil {
+		t.Fatalf("error was not expected, but got: %v", err)
+	}
+	defer stmt.Close()
+	if _, err := stmt.ExecContext(ctx, "hello", 5); err != nil {
+		t.Errorf("error was not expected, but got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPreparedStatementExecMatchesDirectCall(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectPrepare("UPDATE articles").
+		ExpectExec().
+		WithArgs("hello", 5).
+		WillReturnResult(NewResult(0, 1))
+
+	stmt, err := db.Prepare("UPDATE articles SET title = ? WHERE id = ?")
+	if err != nil {
+		t.Fatalf("error was not expected, but got: %v", err)
+	}
+	defer stmt.Close()
+
+	// an exec made directly on the database still matches, as it used to
+	if _, err := db.Exec("UPDATE articles SET title = ? WHERE id = ?", "hello", 5); err != nil {
+		t.Errorf("error was not expected, but got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRejectNamedArgs(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New(RejectNamedArgsOption())
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE users").
+		WithArgs("john", 5).
+		WillReturnResult(NewResult(0, 1))
+
+	_, err = db.Exec("UPDATE users SET name = :name WHERE id = :id", sql.Named("name", "john"), sql.Named("id", 5))
+	if err == nil {
+		t.Fatal("expected an error, since named arguments are rejected")
+	}
+	if !strings.HasSuffix(err.Error(), errNamedArgsRejected.Error()) {
+		t.Errorf("expected error '%s', but got '%s'", errNamedArgsRejected, err)
+	}
+
+	if _, err = db.Exec("UPDATE users SET name = ? WHERE id = ?", "john", 5); err != nil {
+		t.Errorf("error '%s' was not expected, while falling back to positional args", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestExecResultFuncInListSize(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	affected := func(query string, args []driver.NamedValue) (driver.Result, error) {
+		return NewResult(0, int64(len(args))), nil
+	}
+	mock.ExpectExec("DELETE FROM users WHERE id IN").WillReturnResultFunc(affected)
+	mock.ExpectExec("DELETE FROM users WHERE id IN").WillReturnResultFunc(affected)
+	mock.ExpectExec("DELETE FROM users WHERE id IN").
+		WillReturnResultFunc(func(query string, args []driver.NamedValue) (driver.Result, error) {
+			return nil, errors.New("deadlock detected")
+		})
+
+	ids := []interface{}{1, 2, 3}
+	res, err := db.Exec("DELETE FROM users WHERE id IN (?, ?, ?)", ids...)
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while deleting rows", err)
+	}
+	if n, _ := res.RowsAffected(); n != 3 {
+		t.Errorf("expected 3 rows to be affected, but got %d", n)
+	}
+
+	res, err = db.Exec("DELETE FROM users WHERE id IN (?)", 7)
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while deleting rows", err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Errorf("expected 1 row to be affected, but got %d", n)
+	}
+
+	if _, err = db.Exec("DELETE FROM users WHERE id IN (?)", 8); err == nil || err.Error() != "deadlock detected" {
+		t.Errorf("expected error returned by result func, but got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err == nil {
+		t.Error("expected the last exec to remain unfulfilled, since its result func failed")
+	}
+}
+
+func TestWillReturnLastInsertIdFunc(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	contentID := func(args []driver.NamedValue) int64 {
+		var id int64
+		for _, c := range args[0].Value.(string) {
+			id = id*31 + int64(c)
+		}
+		return id
+	}
+	mock.ExpectExec("INSERT INTO blobs").WillReturnLastInsertIdFunc(contentID).Times(2)
+	mock.ExpectExec("INSERT INTO blobs").WillReturnLastInsertIdFunc(contentID).WillReturnResult(NewResult(0, 0))
+
+	var ids []int64
+	for _, content := range []string{"abc", "abc", "abd"} {
+		res, err := db.Exec("INSERT INTO blobs(content) VALUES (?) ON CONFLICT DO NOTHING", content)
+		if err != nil {
+			t.Fatalf("error '%s' was not expected, while inserting a blob", err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			t.Fatalf("error '%s' was not expected, while reading the last insert id", err)
+		}
+		ids = append(ids, id)
+	}
+	if ids[0] != 96354 || ids[1] != ids[0] || ids[2] == ids[0] {
+		t.Errorf("expected retried inserts to get the same id, but got %v", ids)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReadOnlyTransactionRejectsWrites(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin().ReadOnly()
+	mock.ExpectQuery("SELECT id FROM users").WillReturnRows(NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("UPDATE users").WillReturnResult(NewResult(0, 1))
+
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while beginning a transaction", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	if err = tx.QueryRow("SELECT id FROM users WHERE name = ?", "john").Scan(&id); err != nil {
+		t.Fatalf("error '%s' was not expected, while querying a row", err)
+	}
+
+	_, err = tx.Exec("/* audit */ UPDATE users SET name = ? WHERE id = ?", "jane", id)
+	if err == nil {
+		t.Fatal("expected an error, since a write was issued in a read-only transaction")
+	}
+
+	expected := "ExecQuery '/* audit */ UPDATE users SET name = ? WHERE id = ?', cannot execute UPDATE in a read-only transaction"
+	if err.Error() != expected {
+		t.Errorf("expected error '%s', but got '%s'", expected, err)
+	}
+
+	mock.ExpectExec("DELETE FROM users").WillReturnResult(NewResult(0, 1))
+	_, err = tx.Exec("WITH stale AS (SELECT id FROM users) DELETE FROM users WHERE id IN (SELECT id FROM stale)")
+	if err == nil || !strings.HasSuffix(err.Error(), "cannot execute DELETE in a read-only transaction") {
+		t.Errorf("expected a write after a CTE to be rejected, but got: %v", err)
+	}
+}
+
+func TestReadOnlyBeginExpectation(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin().ReadOnly()
+
+	if _, err = db.Begin(); err == nil {
+		t.Error("expected an error, since the transaction was not started as read-only")
+	}
+}
+
+func TestAbortTxOnCancel(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New(AbortTxOnCancelOption(nil))
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT").WillDelayFor(time.Second).WillReturnRows(NewRows([]string{"id"}))
+	mock.ExpectRollback()
+	mock.ExpectExec("UPDATE users").WillReturnResult(NewResult(0, 1))
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while beginning a transaction", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err = tx.QueryContext(ctx, "SELECT id FROM users"); err == nil {
+		t.Fatal("expected an error, since the query was cancelled")
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err = tx.Exec("UPDATE users SET name = ?", "john"); err != ErrTxAborted {
+			t.Fatalf("expected aborted transaction error, but got: %v", err)
+		}
+	}
+
+	if err = tx.Rollback(); err != nil {
+		t.Errorf("error '%s' was not expected, while rolling back a transaction", err)
+	}
+
+	if _, err = db.Exec("UPDATE users SET name = ?", "john"); err != nil {
+		t.Errorf("error '%s' was not expected, after the transaction was rolled back", err)
+	}
+}
+
+func TestAbortTxOnCancelCustomErrorOnCommit(t *testing.T) {
+	t.Parallel()
+	errAborted := errors.New("pq: Could not complete operation in a failed transaction")
+	db, mock, err := New(AbortTxOnCancelOption(errAborted))
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users").WillDelayFor(time.Second).WillReturnResult(NewResult(0, 1))
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while beginning a transaction", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err = tx.ExecContext(ctx, "UPDATE users SET name = ?", "john"); err == nil {
+		t.Fatal("expected an error, since the exec was cancelled")
+	}
+
+	if err = tx.Commit(); err != errAborted {
+		t.Errorf("expected aborted transaction error on commit, but got: %v", err)
+	}
+}
+
+func TestWillReturnRowsFuncFailure(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New(StrictColumnOrderOption())
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	errFunc := errors.New("fixture not loaded")
+	failing := true
+	mock.ExpectQuery("SELECT id, name FROM users").
+		WillReturnRowsFunc(func(query string, args []driver.NamedValue) (*Rows, error) {
+			if failing {
+				return nil, errFunc
+			}
+			return NewRows([]string{"name", "id"}).AddRow("alice", 1), nil
+		})
+
+	if _, err = db.Query("SELECT id, name FROM users"); err != errFunc {
+		t.Fatalf("expected the rows func error, but got: %v", err)
+	}
+	if calls := mock.Calls(); len(calls) != 0 {
+		t.Errorf("expected a failed rows func not to record a call, but got %+v", calls)
+	}
+
+	failing = false
+	if _, err = db.Query("SELECT id, name FROM users"); err == nil {
+		t.Fatal("expected an error, since rows built by the func declare columns in another order")
+	}
+
+	if err := mock.ExpectationsWereMet(); err == nil {
+		t.Error("expected the query expectation to remain unfulfilled")
+	}
+}
+
+func TestWillReturnRowsFuncFiltersFixture(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	fixture := NewRows([]string{"id", "name"}).
+		AddRow(3, "carol").
+		AddRow(1, "alice").
+		AddRow(2, "bob")
+
+	mock.ExpectQuery("SELECT id, name FROM users WHERE id > ?").
+		WillReturnRowsFunc(func(query string, args []driver.NamedValue) (*Rows, error) {
+			min := args[0].Value.(int64)
+			return fixture.Filter(func(row []driver.Value) bool {
+				return row[0].(int64) > min
+			}).Sort(func(a, b []driver.Value) bool {
+				return a[0].(int64) < b[0].(int64)
+			}), nil
+		}).
+		Times(2)
+
+	for _, c := range []struct {
+		min      int
+		expected []string
+	}{
+		{1, []string{"bob", "carol"}},
+		{2, []string{"carol"}},
+	} {
+		rows, err := db.Query("SELECT id, name FROM users WHERE id > ?", c.min)
+		if err != nil {
+			t.Fatalf("error '%s' was not expected, while running a query", err)
+		}
+		var names []string
+		for rows.Next() {
+			var id int
+			var name string
+			if err := rows.Scan(&id, &name); err != nil {
+				t.Fatalf("error '%s' was not expected, while scanning a row", err)
+			}
+			names = append(names, name)
+		}
+		rows.Close()
+		if !reflect.DeepEqual(names, c.expected) {
+			t.Errorf("expected names %v for ids over %d, but got %v", c.expected, c.min, names)
+		}
+	}
+
+	if len(fixture.rows) != 3 {
+		t.Errorf("expected the fixture to keep its 3 rows, but it has %d", len(fixture.rows))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestShardedRoutesByArgument(t *testing.T) {
+	t.Parallel()
+	db, shards, err := NewSharded(2, func(query string, args []driver.NamedValue) (int, error) {
+		if len(args) == 0 {
+			return 0, nil
+		}
+		return int(args[0].Value.(int64) % 2), nil
+	})
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	shards[0].ExpectQuery("SELECT name FROM users").WithArgs(2).WillReturnRows(NewRows([]string{"name"}).AddRow("even"))
+	shards[1].ExpectQuery("SELECT name FROM users").WithArgs(3).WillReturnRows(NewRows([]string{"name"}).AddRow("odd"))
+	shards[1].ExpectBegin()
+	shards[1].ExpectExec("UPDATE users").WithArgs(5, "john").WillReturnResult(NewResult(0, 1))
+	shards[1].ExpectCommit()
+
+	for id, expected := range map[int]string{2: "even", 3: "odd"} {
+		var name string
+		if err := db.QueryRow("SELECT name FROM users WHERE id = ?", id).Scan(&name); err != nil {
+			t.Fatalf("error '%s' was not expected, while querying user %d", err, id)
+		}
+		if name != expected {
+			t.Errorf("expected user %d to be read from the %s shard, but got %s", id, expected, name)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while beginning a transaction", err)
+	}
+	if _, err = tx.Exec("UPDATE users SET active = false WHERE id = ? AND name = ?", 5, "john"); err != nil {
+		t.Fatalf("error '%s' was not expected, while updating a user", err)
+	}
+	if _, err = tx.Exec("UPDATE users SET active = false WHERE id = ? AND name = ?", 4, "jane"); err == nil {
+		t.Error("expected an error, since the transaction is bound to another shard")
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("error '%s' was not expected, while committing a transaction", err)
+	}
+
+	for i, mock := range shards {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations on shard %d: %s", i, err)
+		}
+	}
+}
+
+func TestShardedOptionErrorReleasesShards(t *testing.T) {
+	t.Parallel()
+	var shards []*sqlmock
+	failing := func(s *sqlmock) error {
+		if shards = append(shards, s); len(shards) == 2 {
+			return errors.New("option failed")
+		}
+		return nil
+	}
+	if _, _, err := NewSharded(3, func(string, []driver.NamedValue) (int, error) { return 0, nil }, failing); err == nil {
+		t.Fatal("expected an error, since the option fails")
+	}
+
+	pool.Lock()
+	defer pool.Unlock()
+	for _, shard := range shards {
+		if pool.conns[shard.dsn] == shard {
+			t.Errorf("expected shard '%s' to be released, since the option failed", shard.dsn)
+		}
+	}
+}
+
+func TestShardedCloseReleasesRouter(t *testing.T) {
+	t.Parallel()
+	db, shards, err := NewSharded(2, func(string, []driver.NamedValue) (int, error) { return 0, nil })
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	for _, mock := range shards {
+		mock.ExpectClose()
+	}
+
+	shardPool.Lock()
+	var dsn string
+	for d, r := range shardPool.routers {
+		if r.shards[0] == shards[0] {
+			dsn = d
+		}
+	}
+	shardPool.Unlock()
+	if dsn == "" {
+		t.Fatal("expected the router to be registered while the database is open")
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("error '%s' was not expected, while closing the database", err)
+	}
+	shardPool.Lock()
+	_, ok := shardPool.routers[dsn]
+	shardPool.Unlock()
+	if ok {
+		t.Errorf("expected router '%s' to be released, since the database was closed", dsn)
+	}
+}
+
+func TestShardedPingErrorReleasesRouter(t *testing.T) {
+	t.Parallel()
+	var shards []*sqlmock
+	pingFails := func(s *sqlmock) error {
+		shards = append(shards, s)
+		s.ExpectPing().WillReturnError(errors.New("ping failed"))
+		return nil
+	}
+	if _, _, err := NewSharded(1, func(string, []driver.NamedValue) (int, error) { return 0, nil }, pingFails); err == nil {
+		t.Fatal("expected an error, since the ping fails")
+	}
+
+	shardPool.Lock()
+	defer shardPool.Unlock()
+	for dsn, r := range shardPool.routers {
+		if r.shards[0] == shards[0] {
+			t.Errorf("expected router '%s' to be released, since the ping failed", dsn)
+		}
+	}
+}
+
+func TestWillReturnResultFuncErrorLeavesExpectationOpen(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	errLocked := errors.New("table is locked")
+	mock.ExpectExec("UPDATE (users|accounts)").
+		WillReturnResultFunc(func(query string, args []driver.NamedValue) (driver.Result, error) {
+			if strings.Contains(query, "accounts") {
+				return nil, errLocked
+			}
+			return NewResult(0, 1), nil
+		})
+
+	if _, err = db.Exec("UPDATE accounts SET active = ?", false); err != errLocked {
+		t.Fatalf("expected the result func error to be returned as is, but got: %v", err)
+	}
+	if err = mock.ExpectationsWereMet(); err == nil {
+		t.Error("expected the exec to remain unfulfilled, after the result func failed")
+	}
+
+	if _, err = db.Exec("UPDATE users SET active = ?", false); err != nil {
+		t.Fatalf("error '%s' was not expected, while updating users", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestContextOnlyDriver(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New(ContextOnlyDriverOption())
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	dc, err := pool.Open(mock.(*sqlmock).dsn)
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while opening a driver connection", err)
+	}
+	defer dc.Close()
+	if _, ok := dc.(driver.Queryer); ok {
+		t.Error("expected connection not to implement driver.Queryer")
+	}
+	if _, ok := dc.(driver.Execer); ok {
+		t.Error("expected connection not to implement driver.Execer")
+	}
+	if _, ok := dc.(driver.QueryerContext); !ok {
+		t.Error("expected connection to implement driver.QueryerContext")
+	}
+	if _, ok := dc.(driver.ExecerContext); !ok {
+		t.Error("expected connection to implement driver.ExecerContext")
+	}
+
+	mock.ExpectExec("UPDATE users").WithArgs(1).WillReturnResult(NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(NewRows([]string{"id"}).AddRow(1))
+
+	if _, err = db.Exec("UPDATE users SET active = true WHERE id = ?", 1); err != nil {
+		t.Errorf("error '%s' was not expected, while updating users", err)
+	}
+	rows, err := db.Query("SELECT id FROM users")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while selecting users", err)
+	}
+	rows.Close()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestActualDelay(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	slow := mock.ExpectQuery("SELECT (.+) FROM users").
+		WillReturnRows(NewRows([]string{"id"}).AddRow(1)).
+		WillDelayFor(30 * time.Millisecond)
+	cut := mock.ExpectExec("UPDATE users").
+		WillReturnResult(NewResult(0, 1)).
+		WillDelayFor(time.Second)
+
+	rows, err := db.Query("SELECT id FROM users")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while selecting users", err)
+	}
+	rows.Close()
+	if d := slow.ActualDelay(); d < 30*time.Millisecond {
+		t.Errorf("expected query to be delayed for at least 30ms, but it was delayed for %s", d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err = db.ExecContext(ctx, "UPDATE users SET active = false"); err == nil {
+		t.Error("expected exec to be cancelled")
+	}
+	elapsed := time.Since(start)
+	if d := cut.ActualDelay(); d <= 0 || d > elapsed || d >= time.Second {
+		t.Errorf("expected exec delay to be cut short by cancellation within %s, but it was delayed for %s", elapsed, d)
+	}
+}
+
+func TestRequireContext(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM users").RequireContext().WillReturnRows(NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("UPDATE users").RequireContext().WillReturnResult(NewResult(0, 1))
+
+	rows, err := db.Query("SELECT id FROM users")
+	if err == nil {
+		rows.Close()
+		t.Fatal("expected an error, since the query was made without a context")
+	}
+	if !strings.Contains(err.Error(), "was expected to be called with a context") {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if rows, err = db.QueryContext(ctx, "SELECT id FROM users"); err != nil {
+		t.Fatalf("error '%s' was not expected, while selecting users with a context", err)
+	}
+	rows.Close()
+
+	if _, err = db.Exec("UPDATE users SET active = true"); err == nil {
+		t.Error("expected an error, since the exec was made without a context")
+	}
+	if _, err = db.ExecContext(ctx, "UPDATE users SET active = true"); err != nil {
+		t.Errorf("error '%s' was not expected, while updating users with a context", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestWillReturnRowsFuncRowLevelSecurity(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	documents := NewRows([]string{"title", "owner"}).
+		AddRow("budget", "alice").
+		AddRow("roadmap", "bob").
+		AddRow("minutes", func() driver.Value { return "alice" })
+
+	mock.ExpectQuery("SELECT title, owner FROM documents").
+		WillReturnRowsFunc(func(query string, args []driver.NamedValue) (*Rows, error) {
+			return documents.FilterByColumn("owner", args[0].Value), nil
+		}).
+		Times(2)
+
+	for user, expected := range map[string][]string{"alice": {"budget", "minutes"}, "bob": {"roadmap"}} {
+		rows, err := db.Query("SELECT title, owner FROM documents WHERE owner = current_setting(?)", user)
+		if err != nil {
+			t.Fatalf("error '%s' was not expected, while selecting documents", err)
+		}
+		var titles []string
+		for rows.Next() {
+			var title, owner string
+			if err := rows.Scan(&title, &owner); err != nil {
+				t.Fatalf("error '%s' was not expected, while scanning a row", err)
+			}
+			titles = append(titles, title)
+		}
+		rows.Close()
+		if !reflect.DeepEqual(titles, expected) {
+			t.Errorf("expected %s to see documents %v, but got %v", user, expected, titles)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestWillReturnScalarAfter(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT COUNT").WillReturnScalarAfter(42, 20*time.Millisecond)
+	mock.ExpectQuery("SELECT COUNT").WillReturnScalarAfter(42, time.Second)
+
+	start := time.Now()
+	var count int
+	if err = db.QueryRow("SELECT COUNT(*) FROM events").Scan(&count); err != nil {
+		t.Fatalf("error '%s' was not expected, while counting events", err)
+	}
+	if count != 42 {
+		t.Errorf("expected count to be 42, but got %d", count)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("expected count to be delayed for 20ms, but it took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM events").Scan(&count); err != ErrCancelled {
+		t.Errorf("expected the slow count to be cancelled, but got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
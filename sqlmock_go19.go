@@ -9,6 +9,12 @@ import (
 
 // CheckNamedValue meets https://golang.org/pkg/database/sql/driver/#NamedValueChecker
 func (c *sqlmock) CheckNamedValue(nv *driver.NamedValue) (err error) {
+	if nv.Name != "" && c.rejectNamedArgs {
+		return errNamedArgsRejected
+	}
+	if c.skipValueCheck {
+		return driver.ErrSkip
+	}
 	switch nv.Value.(type) {
 	case sql.Out:
 		return nil
@@ -17,3 +23,9 @@ func (c *sqlmock) CheckNamedValue(nv *driver.NamedValue) (err error) {
 		return err
 	}
 }
+
+// CheckNamedValue meets https://golang.org/pkg/database/sql/driver/#NamedValueChecker
+// shards share the options, so the first one converts values for all
+func (c *shardConn) CheckNamedValue(nv *driver.NamedValue) error {
+	return c.conns[0].CheckNamedValue(nv)
+}
@@ -3,6 +3,8 @@
 package sqlmock
 
 import (
+	"context"
+	"database/sql/driver"
 	"errors"
 	"testing"
 )
@@ -37,3 +39,248 @@ func TestStatementTX(t *testing.T) {
 		t.Fatalf("unexpected result: %v", err)
 	}
 }
+
+func TestPoisonConnection(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE users").WillReturnResult(NewResult(0, 1))
+	mock.ExpectExec("UPDATE users").WillReturnResult(NewResult(0, 1))
+	mock.ExpectExec("UPDATE users").WillReturnResult(NewResult(0, 1))
+
+	ctx := context.Background()
+	pinned, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while opening a connection", err)
+	}
+	if _, err = pinned.ExecContext(ctx, "UPDATE users SET name = ?", "john"); err != nil {
+		t.Fatalf("error '%s' was not expected, while updating a row", err)
+	}
+
+	mock.PoisonConnection()
+
+	if _, err = pinned.ExecContext(ctx, "UPDATE users SET name = ?", "jane"); err != driver.ErrBadConn {
+		t.Fatalf("expected bad connection error on a poisoned connection, but got: %v", err)
+	}
+	pinned.Close()
+
+	// the pool discards the poisoned connection and opens a healthy one
+	if _, err = db.Exec("UPDATE users SET name = ?", "jane"); err != nil {
+		t.Fatalf("error '%s' was not expected, while updating a row on a new connection", err)
+	}
+	if _, err = db.Exec("UPDATE users SET name = ?", "joe"); err != nil {
+		t.Fatalf("error '%s' was not expected, while updating a row on a new connection", err)
+	}
+
+	calls := mock.Calls()
+	if len(calls) != 3 {
+		t.Fatalf("expected 3 calls to be recorded, but got %d", len(calls))
+	}
+	if calls[0].Conn != 1 || calls[1].Conn != 2 || calls[2].Conn != 2 {
+		t.Errorf("expected calls to be made on connections 1, 2 and 2, but got %d, %d and %d", calls[0].Conn, calls[1].Conn, calls[2].Conn)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRePrepareAfterConnectionLoss(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	prep := mock.ExpectPrepare("UPDATE users")
+	prep.ExpectExec().WithArgs("john").WillReturnResult(NewResult(0, 1))
+	prep.ExpectExec().WithArgs("jane").WillReturnResult(NewResult(0, 1))
+
+	stmt, err := db.Prepare("UPDATE users SET name = ?")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while preparing a statement", err)
+	}
+	defer stmt.Close()
+
+	if _, err = stmt.Exec("john"); err != nil {
+		t.Fatalf("error '%s' was not expected, while executing a statement", err)
+	}
+	if n := mock.RePrepareCount("UPDATE users SET name = ?"); n != 0 {
+		t.Errorf("expected statement not to be prepared again yet, but it was %d times", n)
+	}
+
+	mock.PoisonConnection()
+
+	if _, err = stmt.Exec("jane"); err != nil {
+		t.Fatalf("error '%s' was not expected, while executing a statement on a new connection", err)
+	}
+	if n := mock.RePrepareCount("UPDATE users SET name = ?"); n != 1 {
+		t.Errorf("expected statement to be prepared again once, but it was %d times", n)
+	}
+
+	history := mock.PrepareHistory()
+	if len(history) != 2 {
+		t.Fatalf("expected 2 prepares in history, but got %+v", history)
+	}
+	if !history[1].RePrepared || history[1].Conn == history[0].Conn || history[1].Query != history[0].Query {
+		t.Errorf("expected the statement to be re-prepared with the same SQL on a new connection, but got %+v", history)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPrepareAfterIdleConnectionClosed(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectPrepare("UPDATE users")
+	mock.ExpectPrepare("UPDATE users")
+
+	ctx := context.Background()
+	pinned, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while opening a connection", err)
+	}
+	defer pinned.Close()
+
+	// connections are closed by the pool as soon as they are released
+	db.SetMaxIdleConns(0)
+
+	first, err := db.Prepare("UPDATE users SET name = ?")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while preparing a statement", err)
+	}
+	defer first.Close()
+
+	stmt, err := db.Prepare("UPDATE users SET name = ?")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while preparing a statement", err)
+	}
+	defer stmt.Close()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expected the second prepare to match its own expectation, but got: %s", err)
+	}
+	if n := mock.RePrepareCount("UPDATE users SET name = ?"); n != 0 {
+		t.Errorf("expected statement not to be prepared again, but it was %d times", n)
+	}
+	for _, record := range mock.PrepareHistory() {
+		if record.RePrepared {
+			t.Errorf("expected no prepare to be recorded as re-prepared, but got %+v", record)
+		}
+	}
+}
+
+func TestRePrepareAfterBadConn(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	prep := mock.ExpectPrepare("UPDATE users")
+	prep.ExpectExec().WillReturnError(driver.ErrBadConn)
+	prep.ExpectExec().WillReturnResult(NewResult(0, 1))
+
+	stmt, err := db.Prepare("UPDATE users SET name = ?")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while preparing a statement", err)
+	}
+	defer stmt.Close()
+
+	// database/sql retries the exec on a new connection
+	if _, err = stmt.Exec("john"); err != nil {
+		t.Fatalf("error '%s' was not expected, while executing a statement", err)
+	}
+	if n := mock.RePrepareCount("UPDATE users SET name = ?"); n != 1 {
+		t.Errorf("expected statement to be prepared again once, but it was %d times", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStatementStats(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	prep := mock.ExpectPrepare("UPDATE users")
+	for _, name := range []string{"john", "jack", "jane"} {
+		prep.ExpectExec().WithArgs(name).WillReturnResult(NewResult(0, 1))
+	}
+
+	stmt, err := db.Prepare("UPDATE users SET name = ?")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while preparing a statement", err)
+	}
+	for _, name := range []string{"john", "jack"} {
+		if _, err = stmt.Exec(name); err != nil {
+			t.Fatalf("error '%s' was not expected, while executing a statement", err)
+		}
+	}
+	mock.PoisonConnection()
+	if _, err = stmt.Exec("jane"); err != nil {
+		t.Fatalf("error '%s' was not expected, while executing a statement on a new connection", err)
+	}
+	if err = stmt.Close(); err != nil {
+		t.Fatalf("error '%s' was not expected, while closing a statement", err)
+	}
+
+	stats := mock.StatementStats("UPDATE users SET name = ?")
+	expected := StmtStats{Query: "UPDATE users SET name = ?", Prepares: 2, RePrepares: 1, Executions: 3, Reuses: 1, Closes: 2}
+	if stats != expected {
+		t.Errorf("expected statement stats %+v, but got %+v", expected, stats)
+	}
+	if stats := mock.StatementStats("DELETE FROM users"); stats.Prepares != 0 {
+		t.Errorf("expected no stats of a statement never prepared, but got %+v", stats)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+type failingConverter struct{}
+
+func (failingConverter) ConvertValue(v interface{}) (driver.Value, error) {
+	return nil, errors.New("converter was not expected to be used")
+}
+
+func TestSkipNamedValueCheck(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New(ValueConverterOption(failingConverter{}), SkipNamedValueCheckOption())
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE users").WithArgs(AnyArg()).WillReturnResult(NewResult(0, 1))
+
+	if _, err = db.Exec("UPDATE users SET active = true WHERE id = ?", 1); err != nil {
+		t.Errorf("error '%s' was not expected, since arguments are converted by database/sql", err)
+	}
+	if args := mock.Calls()[0].Args; len(args) != 1 || args[0] != int64(1) {
+		t.Errorf("expected argument to be converted to int64, but got %#v", args)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -2,9 +2,13 @@ package sqlmock
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
+	"reflect"
+	"regexp"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -1217,3 +1221,1303 @@ func queryWithTimeout(t time.Duration, db *sql.DB, query string, args ...interfa
 		return nil, fmt.Errorf("query timed out after %v", t)
 	}
 }
+
+func TestExecExpectedTimes(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE articles").Times(2).WillReturnResult(NewResult(0, 1))
+
+	if _, err := db.Exec("UPDATE articles SET views = views + 1"); err != nil {
+		t.Errorf("error '%s' was not expected, while updating a row", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err == nil {
+		t.Error("expected an error, since exec was called only once")
+	}
+
+	if _, err := db.Exec("UPDATE articles SET views = views + 1"); err != nil {
+		t.Errorf("error '%s' was not expected, while updating a row", err)
+	}
+
+	if _, err := db.Exec("UPDATE articles SET views = views + 1"); err == nil {
+		t.Error("expected an error, since exec was expected to be called only twice")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestExecResultsSequence(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("DELETE FROM sessions").
+		Times(3).
+		WillReturnResultsSequence([]driver.Result{
+			NewResult(0, 5),
+			NewResult(0, 2),
+			NewResult(0, 0),
+		})
+
+	for i, expected := range []int64{5, 2, 0} {
+		res, err := db.Exec("DELETE FROM sessions WHERE batch = ?", i)
+		if err != nil {
+			t.Fatalf("error '%s' was not expected, while deleting rows", err)
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			t.Fatalf("error '%s' was not expected, while reading affected rows", err)
+		}
+		if affected != expected {
+			t.Errorf("expected %d affected rows at call %d, but got %d", expected, i, affected)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestExecResultsSequenceExhausted(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("DELETE FROM sessions").
+		Times(2).
+		WillReturnResultsSequence([]driver.Result{NewResult(0, 1)})
+
+	if _, err := db.Exec("DELETE FROM sessions"); err != nil {
+		t.Errorf("error '%s' was not expected, while deleting rows", err)
+	}
+
+	if _, err := db.Exec("DELETE FROM sessions"); err == nil {
+		t.Error("expected an error, since the results sequence was exhausted")
+	}
+}
+
+func TestStrictPlaceholderArity(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New(StrictPlaceholderArityOption())
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectExec("UPDATE users").WillReturnResult(NewResult(0, 1))
+	mock.ExpectQuery("SELECT").WillReturnRows(NewRows([]string{"id"}))
+
+	_, err = db.Exec("UPDATE users SET name = ? WHERE id = ?", "john")
+	if err == nil {
+		t.Fatal("expected an error, since placeholder count does not match arguments")
+	}
+
+	expected := "ExecQuery: query 'UPDATE users SET name = ? WHERE id = ?' has 2 placeholders, but 1 arguments were bound"
+	if err.Error() != expected {
+		t.Errorf("expected error '%s', but got '%s'", expected, err)
+	}
+
+	if _, err = db.Exec("UPDATE users SET name = ? WHERE id = ?", "john", 1); err != nil {
+		t.Errorf("error '%s' was not expected, while updating a row", err)
+	}
+
+	rows, err := db.Query("SELECT id FROM users WHERE id = $1", 1)
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while querying rows", err)
+	}
+	rows.Close()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRequireArgsForPlaceholders(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New(RequireArgsForPlaceholdersOption())
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE users").WillReturnResult(NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM sessions").WillReturnResult(NewResult(0, 3))
+
+	_, err = db.Exec("UPDATE users SET name = ? WHERE id = ?")
+	expected := "ExecQuery: query 'UPDATE users SET name = ? WHERE id = ?' has 2 placeholders, but was called without arguments"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s', but got '%v'", expected, err)
+	}
+
+	if _, err = db.Exec("UPDATE users SET name = ? WHERE id = ?", "john"); err != nil {
+		t.Errorf("error '%s' was not expected, since arity is not checked", err)
+	}
+	if _, err = db.Exec("DELETE FROM sessions WHERE expired"); err != nil {
+		t.Errorf("error '%s' was not expected, since the statement has no placeholders", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestExecInTransactionConstraints(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE users").InAutoCommit().WillReturnResult(NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO users").InTransaction().WillReturnResult(NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT id FROM users").InTransaction().WillReturnRows(NewRows([]string{"id"}))
+
+	if _, err = db.Exec("UPDATE users SET name = ?", "john"); err != nil {
+		t.Errorf("error '%s' was not expected, while updating a row", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while beginning a transaction", err)
+	}
+	if _, err = tx.Exec("INSERT INTO users(name) VALUES (?)", "jane"); err != nil {
+		t.Errorf("error '%s' was not expected, while inserting a row", err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Errorf("error '%s' was not expected, while committing a transaction", err)
+	}
+
+	_, err = db.Query("SELECT id FROM users")
+	if err == nil {
+		t.Fatal("expected an error, since query was not called within a transaction")
+	}
+
+	expected := "Query 'SELECT id FROM users', was expected to be called within a transaction"
+	if err.Error() != expected {
+		t.Errorf("expected error '%s', but got '%s'", expected, err)
+	}
+
+	calls := mock.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls to be recorded, but got %d", len(calls))
+	}
+	if calls[0].Kind != CallExec || calls[0].InTx {
+		t.Errorf("expected first call to be an exec in auto-commit mode, but got %+v", calls[0])
+	}
+	if calls[1].Query != "INSERT INTO users(name) VALUES (?)" || !calls[1].InTx {
+		t.Errorf("expected second call to be an insert within a transaction, but got %+v", calls[1])
+	}
+	if len(calls[1].Args) != 1 || calls[1].Args[0] != "jane" {
+		t.Errorf("expected second call to be made with argument 'jane', but got %v", calls[1].Args)
+	}
+	if calls[0].Conn != 1 || calls[1].Conn != 1 {
+		t.Errorf("expected calls to be made on the first connection, but got %d and %d", calls[0].Conn, calls[1].Conn)
+	}
+}
+
+func TestUnorderedInTransactionConstraints(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectExec("UPDATE users").InTransaction().WillReturnResult(NewResult(0, 2))
+	mock.ExpectExec("UPDATE users").InAutoCommit().WillReturnResult(NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	res, err := db.Exec("UPDATE users SET name = ?", "john")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while updating a row", err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Errorf("expected auto-commit exec to affect 1 row, but got %d", n)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while beginning a transaction", err)
+	}
+	if res, err = tx.Exec("UPDATE users SET name = ?", "jane"); err != nil {
+		t.Fatalf("error '%s' was not expected, while updating a row", err)
+	}
+	if n, _ := res.RowsAffected(); n != 2 {
+		t.Errorf("expected transactional exec to affect 2 rows, but got %d", n)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Errorf("error '%s' was not expected, while rolling back a transaction", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestMaxDelayOption(t *testing.T) {
+	t.Parallel()
+	var logged []string
+	logf := func(format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}
+	db, mock, err := New(MaxDelayOption(10*time.Millisecond, logf))
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE users").WillDelayFor(time.Hour).WillReturnResult(NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM users").WillDelayFor(time.Millisecond).WillReturnResult(NewResult(0, 1))
+
+	start := time.Now()
+	if _, err = db.Exec("UPDATE users SET name = ?", "john"); err != nil {
+		t.Errorf("error '%s' was not expected, while updating a row", err)
+	}
+	if _, err = db.Exec("DELETE FROM users"); err != nil {
+		t.Errorf("error '%s' was not expected, while deleting rows", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected delays to be clamped, but execution took %s", elapsed)
+	}
+
+	if len(logged) != 1 {
+		t.Fatalf("expected exactly one clamped delay to be logged, but got %d", len(logged))
+	}
+	expected := "sqlmock: delay of 1h0m0s was clamped to 10ms"
+	if logged[0] != expected {
+		t.Errorf("expected log message '%s', but got '%s'", expected, logged[0])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestQueryRequiresHint(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT").RequiresHint("INDEX(users idx_email)").WillReturnRows(NewRows([]string{"id"}))
+	mock.ExpectExec("UPDATE").RequiresHint("NO_MERGE").WillReturnResult(NewResult(0, 1))
+
+	rows, err := db.Query("SELECT /*+ INDEX(users idx_email) */ id FROM users WHERE email = ?", "john@example.com")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while querying rows", err)
+	}
+	rows.Close()
+
+	_, err = db.Exec("UPDATE /*+ MERGE */ users SET name = ?", "john")
+	if err == nil {
+		t.Fatal("expected an error, since the optimizer hint is missing")
+	}
+
+	expected := `ExecQuery 'UPDATE /*+ MERGE */ users SET name = ?', was expected to contain optimizer hint 'NO_MERGE', but got hints ["MERGE"]`
+	if err.Error() != expected {
+		t.Errorf("expected error '%s', but got '%s'", expected, err)
+	}
+
+	calls := mock.Calls()
+	if len(calls) != 1 || len(calls[0].Hints) != 1 || calls[0].Hints[0] != "INDEX(users idx_email)" {
+		t.Errorf("expected query hint to be recorded in the call log, but got %+v", calls)
+	}
+}
+
+func TestQueryRequiresIndexHint(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT").RequiresIndexHint("idx_users_email", "email").WillReturnRows(NewRows([]string{"id"}))
+	mock.ExpectExec("UPDATE").RequiresIndexHint("idx_users_email", "email").WillReturnResult(NewResult(0, 1))
+
+	rows, err := db.Query("SELECT id FROM users /* index: idx_users_email */ WHERE email = ?", "john@example.com")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while querying rows", err)
+	}
+	rows.Close()
+
+	_, err = db.Exec("UPDATE users /* index: idx_users_email */ SET name = ? WHERE lower(email) = ?", "john", "john@example.com")
+	if err == nil {
+		t.Fatal("expected an error, since the index can not be used")
+	}
+
+	expected := "ExecQuery 'UPDATE users /* index: idx_users_email */ SET name = ? WHERE lower(email) = ?', has no index friendly predicate on leading column 'email' in WHERE clause"
+	if err.Error() != expected {
+		t.Errorf("expected error '%s', but got '%s'", expected, err)
+	}
+}
+
+func TestRequiresSQLComment(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	tags := map[string]string{"application": "billing", "route": "/invoices/{id}"}
+	mock.ExpectQuery("SELECT").RequiresSQLComment(tags).WillReturnRows(NewRows([]string{"id"}))
+	mock.ExpectExec("UPDATE").RequiresSQLComment(tags).WillReturnResult(NewResult(0, 1))
+
+	rows, err := db.Query("SELECT /*+ INDEX(invoices) */ id FROM invoices WHERE id = ? /*application='billing',controller='invoice',route='%2Finvoices%2F%7Bid%7D'*/", 1)
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while selecting invoices", err)
+	}
+	rows.Close()
+
+	for query, expected := range map[string]string{
+		"UPDATE invoices SET paid = true /*application='billing',route='%2Finvoices'*/":          "was expected to have SQL comment tag route='/invoices/{id}', but got '/invoices'",
+		"UPDATE invoices SET paid = true /*route='%2Finvoices%2F%7Bid%7D'*/":                     "was expected to have SQL comment tag application='billing', but it is missing",
+		"UPDATE invoices SET paid = true":                                                        "has no trailing SQL comment",
+		"UPDATE invoices SET paid = true /*application=billing,route='%2Finvoices%2F%7Bid%7D'*/": "has a malformed SQL comment tag 'application=billing'",
+	} {
+		_, err = db.Exec(query)
+		if err == nil || !strings.HasSuffix(err.Error(), expected) {
+			t.Errorf("expected error to end with '%s', but got '%v'", expected, err)
+		}
+	}
+
+	if _, err = db.Exec("UPDATE invoices SET paid = true /*route='%2Finvoices%2F%7Bid%7D',application='billing'*/;"); err != nil {
+		t.Errorf("error '%s' was not expected, while updating invoices", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestExecWillReturnVersionConflict(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	update := "UPDATE users SET name = ?, version = version + 1 WHERE id = ? AND version = ?"
+	mock.ExpectExec(regexp.QuoteMeta(update)).WithArgs("john", 1, 3).WillReturnVersionConflict()
+	mock.ExpectQuery("SELECT version FROM users").WithArgs(1).WillReturnRows(NewRows([]string{"version"}).AddRow(4))
+	mock.ExpectExec(regexp.QuoteMeta(update)).WithArgs("john", 1, 4).WillReturnResult(NewResult(0, 1))
+
+	version, attempts := 3, 0
+	for {
+		attempts++
+		res, err := db.Exec(update, "john", 1, version)
+		if err != nil {
+			t.Fatalf("error '%s' was not expected, while updating a user", err)
+		}
+		if affected, _ := res.RowsAffected(); affected == 1 {
+			break
+		}
+		if err = db.QueryRow("SELECT version FROM users WHERE id = ?", 1).Scan(&version); err != nil {
+			t.Fatalf("error '%s' was not expected, while refreshing a user version", err)
+		}
+	}
+
+	if attempts != 2 {
+		t.Errorf("expected the update to be retried once after a version conflict, but it was attempted %d times", attempts)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCallsRecordTransactions(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO users").WillReturnResult(NewResult(1, 1))
+	mock.ExpectQuery("SELECT id FROM users").WillReturnRows(NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	mock.ExpectExec("DELETE FROM sessions").WillReturnResult(NewResult(0, 1))
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while beginning a transaction", err)
+	}
+	if _, err = tx.Exec("INSERT INTO users(name) VALUES (?)", "john"); err != nil {
+		t.Fatalf("error '%s' was not expected, while inserting a user", err)
+	}
+	var id int
+	if err = tx.QueryRow("SELECT id FROM users WHERE name = ?", "john").Scan(&id); err != nil {
+		t.Fatalf("error '%s' was not expected, while querying a user", err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("error '%s' was not expected, while committing a transaction", err)
+	}
+	if err := mock.AssertAllInTransaction(); err != nil {
+		t.Errorf("expected calls of the transaction to be reported as transactional, but got: %s", err)
+	}
+	if _, err = db.Exec("DELETE FROM sessions"); err != nil {
+		t.Fatalf("error '%s' was not expected, while deleting sessions", err)
+	}
+
+	calls := mock.Calls()
+	if calls[0].Tx != 1 || calls[1].Tx != 1 || calls[2].Tx != 0 {
+		t.Errorf("expected transaction ids to be recorded in the call log, but got %+v", calls)
+	}
+	if calls[0].Conn != calls[1].Conn {
+		t.Errorf("expected calls of the transaction to be recorded on one connection, but got %+v", calls)
+	}
+
+	expected := "expected all calls to be made within a transaction, but Exec 'DELETE FROM sessions' was made in auto-commit mode on connection 1"
+	if err := mock.AssertAllInTransaction(); err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s', but got '%v'", expected, err)
+	}
+}
+
+func TestAllBoundArgs(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO users").WithArgs("john", "secret").WillReturnResult(NewResult(1, 1))
+
+	if _, err = db.Exec("INSERT INTO users(name, password) VALUES (?, ?)", "john", "secret"); err != nil {
+		t.Fatalf("error '%s' was not expected, while inserting a user", err)
+	}
+	if _, err = db.Query("SELECT id FROM users WHERE token = ?", "secret"); err == nil {
+		t.Fatal("expected an error, since the query was not expected")
+	}
+
+	var leaks int
+	for _, args := range mock.AllBoundArgs() {
+		for _, arg := range args {
+			if arg == "secret" {
+				leaks++
+			}
+		}
+	}
+	if leaks != 2 {
+		t.Errorf("expected the secret to be bound by both calls, but it was bound %d times", leaks)
+	}
+}
+
+func TestExecWillReturnWarnings(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	warnings := NewRows([]string{"level", "code", "message"}).
+		AddRow("Warning", 1265, "Data truncated for column 'name' at row 1")
+	ex := mock.ExpectExec("INSERT INTO users").WillReturnResult(NewResult(1, 1)).WillReturnWarnings(warnings)
+	mock.ExpectExec("INSERT INTO users").WillReturnResult(NewResult(2, 1)).WillReturnWarnings(warnings)
+
+	if _, err = db.Exec("INSERT INTO users(name) VALUES (?)", "john"); err != nil {
+		t.Fatalf("error '%s' was not expected, while inserting a user", err)
+	}
+	if ex.WarningsProduced() != 1 {
+		t.Errorf("expected the exec to produce warnings once, but got %d", ex.WarningsProduced())
+	}
+
+	// warnings can be read only from the driver connection
+	dc, err := db.Driver().Open(mock.(*sqlmock).dsn)
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while opening a driver connection", err)
+	}
+	res, err := dc.(driver.Execer).Exec("INSERT INTO users(name) VALUES (?)", []driver.Value{"jane"})
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while inserting a user", err)
+	}
+	wr, ok := res.(WarningsResult)
+	if !ok {
+		t.Fatalf("expected the driver result to implement WarningsResult, but got %T", res)
+	}
+	rows := wr.Warnings()
+	dest := make([]driver.Value, 3)
+	if err = rows.Next(dest); err != nil {
+		t.Fatalf("error '%s' was not expected, while reading a warning", err)
+	}
+	if dest[1] != int64(1265) {
+		t.Errorf("expected warning code 1265, but got %v", dest[1])
+	}
+}
+
+func TestSensitiveArgsRedaction(t *testing.T) {
+	t.Parallel()
+	var matched []Call
+	var ex *ExpectedExec
+	db, mock, err := New(OnMatchOption(func(call Call) {
+		ex.ActualDelay() // the expectation is not locked by the hook caller
+		matched = append(matched, call)
+	}))
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ex = mock.ExpectExec("INSERT INTO users").WithSensitiveArgs(2).WillReturnResult(NewResult(1, 1))
+
+	// a logging middleware, which is supposed to redact passwords
+	var logged []driver.Value
+	insert := func(args ...interface{}) error {
+		logged = []driver.Value{args[0], "***"}
+		_, err := db.Exec("INSERT INTO users(name, password) VALUES (?, ?)", args...)
+		return err
+	}
+	if err = insert("john", "secret"); err != nil {
+		t.Fatalf("error '%s' was not expected, while inserting a user", err)
+	}
+
+	if len(matched) != 1 {
+		t.Fatalf("expected the hook to be called once, but it was called %d times", len(matched))
+	}
+	if matched[0].Args[1] != "secret" {
+		t.Errorf("expected the driver to receive the raw password, but got %v", matched[0].Args[1])
+	}
+	if redacted := matched[0].RedactedArgs("***"); !reflect.DeepEqual(redacted, logged) {
+		t.Errorf("expected the logger to see %v, but it saw %v", redacted, logged)
+	}
+}
+
+func TestRollbackOnPanic(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE accounts").WillReturnResult(NewResult(0, 1))
+	mock.ExpectRollback()
+
+	transfer := func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if p := recover(); p != nil {
+				tx.Rollback()
+				panic(p)
+			}
+		}()
+		if _, err = tx.Exec("UPDATE accounts SET balance = balance - ?", 10); err != nil {
+			return err
+		}
+		panic("unexpected state")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the transfer to panic")
+			}
+		}()
+		transfer()
+	}()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expected the transaction to be rolled back during panic unwinding, but: %s", err)
+	}
+}
+
+func TestExpectCompiledRegexp(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New(QueryMatcherOption(QueryMatcherEqual))
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	byID := regexp.MustCompile(`^SELECT (.+) FROM users WHERE id = \?$`)
+	for i := 1; i <= 2; i++ {
+		mock.ExpectQueryRegexp(byID).WithArgs(i).WillReturnRows(NewRows([]string{"name"}).AddRow("john"))
+	}
+	mock.ExpectExecRegexp(regexp.MustCompile(`^DELETE FROM users`)).WillReturnResult(NewResult(0, 1))
+
+	for i := 1; i <= 2; i++ {
+		var name string
+		if err = db.QueryRow("SELECT name\n FROM users WHERE id = ?", i).Scan(&name); err != nil {
+			t.Fatalf("error '%s' was not expected, while querying user %d", err, i)
+		}
+	}
+
+	_, err = db.Exec("UPDATE users SET name = ?", "jane")
+	expected := `could not match actual sql: "UPDATE users SET name = ?" with expected regexp "^DELETE FROM users"`
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("expected error containing '%s', but got '%v'", expected, err)
+	}
+}
+
+func TestExpectCompiledRegexpNormalized(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New(QuoteAgnosticMatchingOption(), IgnoreTrailingPredicateOption(`AND tenant_id = \?`))
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQueryRegexp(regexp.MustCompile(`^SELECT id FROM users WHERE name = \?$`)).
+		WithArgs("john").
+		WithTrailingArgs(42).
+		WillReturnRows(NewRows([]string{"id"}).AddRow(1))
+
+	var id int
+	if err = db.QueryRow(`SELECT "id" FROM "users" WHERE "name" = ? AND tenant_id = ?`, "john", 42).Scan(&id); err != nil {
+		t.Fatalf("error '%s' was not expected, while querying a row", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDistinctQueries(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("CREATE TABLE").WillReturnResult(NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO users").WillReturnResult(NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO users").WillReturnResult(NewResult(2, 1))
+	mock.ExpectExec("UPDATE users").WillReturnResult(NewResult(0, 1))
+	mock.ExpectExec("UPDATE users").WillReturnResult(NewResult(0, 1))
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{"CREATE TABLE users (id INT, name TEXT)", nil},
+		{"INSERT INTO users (name) VALUES (?)", []interface{}{"john"}},
+		{"INSERT INTO users (name)\n  VALUES (?)", []interface{}{"jane"}},
+		{"UPDATE users SET name = 'john' WHERE id = 1", nil},
+		{"UPDATE users SET name = 'jane' WHERE id = 2", nil},
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt.query, stmt.args...); err != nil {
+			t.Fatalf("error '%s' was not expected, while executing '%s'", err, stmt.query)
+		}
+	}
+
+	if n := mock.DistinctQueryCount(); n != 3 {
+		t.Errorf("expected 3 distinct queries, but got %d", n)
+	}
+	queries := mock.DistinctQueries()
+	if len(queries) != 3 || queries[1] != "INSERT INTO users (name) VALUES (?)" || queries[2] != "UPDATE users SET name = ? WHERE id = ?" {
+		t.Errorf("unexpected distinct queries: %q", queries)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTableAllowlist(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New(TableAllowlistOption([]string{"users", "orders"}))
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectQuery("SELECT o.id").WillReturnRows(NewRows([]string{"id"}))
+	mock.ExpectExec("DELETE FROM user").WillReturnResult(NewResult(0, 1))
+	mock.ExpectQuery("SELECT EXTRACT").SkipTableAllowlist().WillReturnRows(NewRows([]string{"year"}))
+
+	rows, err := db.Query("SELECT o.id FROM Users u JOIN public.orders o ON o.user_id = u.id")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while querying rows", err)
+	}
+	rows.Close()
+
+	_, err = db.Exec("DELETE FROM user WHERE id = ?", 1)
+	if err == nil {
+		t.Fatal("expected an error, since the table is not in the allowlist")
+	}
+
+	expected := "ExecQuery 'DELETE FROM user WHERE id = ?', references table 'user', which is not in the table allowlist"
+	if err.Error() != expected {
+		t.Errorf("expected error '%s', but got '%s'", expected, err)
+	}
+
+	rows, err = db.Query("SELECT EXTRACT(year FROM created_at) FROM orders")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while querying rows", err)
+	}
+	rows.Close()
+
+	if _, err = db.Exec("DELETE FROM users WHERE id = ?", 1); err != nil {
+		t.Errorf("error '%s' was not expected, while deleting a row", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestQuoteAgnosticMatching(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New(QuoteAgnosticMatchingOption(), QueryMatcherOption(QueryMatcherEqual))
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	for _, query := range []string{
+		"SELECT `id` FROM `users` WHERE `name` = ?",
+		`SELECT "id" FROM "users" WHERE "name" = ?`,
+		"SELECT [id] FROM [users] WHERE [name] = ?",
+	} {
+		mock.ExpectQuery("SELECT id FROM users WHERE name = ?").
+			WithArgs("john").
+			WillReturnRows(NewRows([]string{"id"}).AddRow(1))
+
+		var id int
+		if err := db.QueryRow(query, "john").Scan(&id); err != nil {
+			t.Errorf("error '%s' was not expected, while querying '%s'", err, query)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestIgnoreTrailingPredicate(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New(IgnoreTrailingPredicateOption(`AND tenant_id = \?`), QueryMatcherOption(QueryMatcherEqual))
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id FROM users WHERE name = ?").
+		WithArgs("john").
+		WithTrailingArgs(42).
+		WillReturnRows(NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("UPDATE users SET name = ? WHERE id = ?").
+		WithArgs("jane", 1).
+		WithTrailingArgs(42).
+		WillReturnResult(NewResult(0, 1))
+
+	var id int
+	if err = db.QueryRow("SELECT id FROM users WHERE name = ? AND tenant_id = ?", "john", 42).Scan(&id); err != nil {
+		t.Fatalf("error '%s' was not expected, while querying a row", err)
+	}
+
+	_, err = db.Exec("UPDATE users SET name = ? WHERE id = ?\n AND tenant_id = ?", "jane", 1, 7)
+	if err == nil {
+		t.Fatal("expected an error, since the tenant argument does not match")
+	}
+
+	expected := "ExecQuery 'UPDATE users SET name = ? WHERE id = ?\n AND tenant_id = ?', trailing predicate arguments do not match: argument 0 expected [int64 - 42] does not match actual [int64 - 7]"
+	if err.Error() != expected {
+		t.Errorf("expected error '%s', but got '%s'", expected, err)
+	}
+}
+
+func TestErrorsSequence(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	errRateLimit := errors.New("rate limit exceeded")
+	errTimeout := errors.New("i/o timeout")
+	mock.ExpectExec("UPDATE users").
+		WillReturnErrorsSequence([]error{errRateLimit, errTimeout, nil}).
+		WillReturnResult(NewResult(0, 1))
+	mock.ExpectQuery("SELECT id FROM users").
+		WillReturnErrorsSequence([]error{errTimeout, nil}).
+		WillReturnRows(NewRows([]string{"id"}).AddRow(1))
+
+	for i, expected := range []error{errRateLimit, errTimeout, nil} {
+		if _, err := db.Exec("UPDATE users SET name = ?", "john"); err != expected {
+			t.Errorf("expected error '%v' on attempt %d, but got '%v'", expected, i+1, err)
+		}
+	}
+
+	if _, err := db.Query("SELECT id FROM users"); err != errTimeout {
+		t.Errorf("expected error '%v' on first query attempt, but got '%v'", errTimeout, err)
+	}
+	rows, err := db.Query("SELECT id FROM users")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected on second query attempt", err)
+	}
+	rows.Close()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestQueryExpectedTimes(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id FROM users").Times(3).WillReturnRows(NewRows([]string{"id"}).AddRow(1))
+
+	for i := 0; i < 2; i++ {
+		var id int
+		if err := db.QueryRow("SELECT id FROM users").Scan(&id); err != nil {
+			t.Fatalf("error '%s' was not expected, while querying a row on call %d", err, i+1)
+		}
+		if id != 1 {
+			t.Errorf("expected id 1 to be returned on call %d, but got %d", i+1, id)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err == nil {
+		t.Error("expected an error, since the query was called only twice")
+	}
+}
+
+func TestAssertStableFingerprints(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id FROM users WHERE id").Times(2).WillReturnRows(NewRows([]string{"id"}))
+	mock.ExpectExec("DELETE FROM users WHERE id IN").Times(2).WillReturnResult(NewResult(0, 1))
+
+	for _, id := range []int{1, 2} {
+		rows, err := db.Query(fmt.Sprintf("SELECT id FROM users WHERE id = %d", id))
+		if err != nil {
+			t.Fatalf("error '%s' was not expected, while querying rows", err)
+		}
+		rows.Close()
+	}
+
+	if err := mock.AssertStableFingerprints(); err != nil {
+		t.Errorf("expected stable fingerprints, but got: %s", err)
+	}
+	if fp := mock.Calls()[0].Fingerprint; fp != "SELECT id FROM users WHERE id = ?" {
+		t.Errorf("unexpected call fingerprint: %s", fp)
+	}
+
+	if _, err = db.Exec("DELETE FROM users WHERE id IN (?)", 1); err != nil {
+		t.Fatalf("error '%s' was not expected, while deleting rows", err)
+	}
+	if _, err = db.Exec("DELETE FROM users WHERE id IN (?, ?)", 1, 2); err != nil {
+		t.Fatalf("error '%s' was not expected, while deleting rows", err)
+	}
+
+	if err := mock.AssertStableFingerprints(); err == nil {
+		t.Error("expected an error, since delete calls had different fingerprints")
+	}
+}
+
+func TestAssertCalledOnce(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectQuery("SELECT (.+) FROM products").WillReturnRows(NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("UPDATE stats").Times(2).WillReturnResult(NewResult(0, 1))
+
+	for i := 0; i < 2; i++ {
+		if _, err = db.Exec("UPDATE stats SET hits = hits + 1"); err != nil {
+			t.Fatalf("error '%s' was not expected, while updating stats", err)
+		}
+	}
+	rows, err := db.Query("SELECT id FROM products")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while selecting products", err)
+	}
+	rows.Close()
+
+	if err := mock.AssertCalledOnce("SELECT (.+) FROM products"); err != nil {
+		t.Errorf("expected products to be selected once, but got: %s", err)
+	}
+	if err := mock.AssertCalledOnce("UPDATE stats"); err == nil {
+		t.Error("expected an error, since stats were updated twice")
+	}
+	if err := mock.AssertCalledOnce("DELETE FROM products"); err == nil {
+		t.Error("expected an error, since products were not deleted")
+	}
+}
+
+func TestWillSkipFastPath(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE users").WithArgs(1).WillSkipFastPath().WillReturnResult(NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) FROM users").WillSkipFastPath().WillReturnRows(NewRows([]string{"id"}).AddRow(1))
+
+	if _, err = db.Exec("UPDATE users SET active = true WHERE id = ?", 1); err != nil {
+		t.Fatalf("error '%s' was not expected, while updating users", err)
+	}
+	var id int
+	if err = db.QueryRow("SELECT id FROM users").Scan(&id); err != nil {
+		t.Fatalf("error '%s' was not expected, while selecting users", err)
+	}
+
+	history := mock.PrepareHistory()
+	if len(history) != 2 {
+		t.Fatalf("expected both calls to fall back to prepared statements, but got %d prepares", len(history))
+	}
+	if history[0].Query != "UPDATE users SET active = true WHERE id = ?" {
+		t.Errorf("unexpected statement prepared: %s", history[0].Query)
+	}
+	if calls := mock.Calls(); len(calls) != 2 {
+		t.Errorf("expected 2 calls to be recorded once each, but got %d", len(calls))
+	}
+	if bound := mock.AllBoundArgs(); len(bound) != 2 {
+		t.Errorf("expected arguments of 2 calls to be bound once each, but got %v", bound)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSkippedFastPathIsForgotten(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectExec("UPDATE users").WillSkipFastPath().WillReturnResult(NewResult(0, 1))
+	prep := mock.ExpectPrepare("UPDATE users")
+
+	cn, err := pool.open(mock.(*sqlmock).dsn)
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while opening a driver connection", err)
+	}
+	defer cn.Close()
+
+	query := "UPDATE users SET active = true"
+	if _, err = cn.Exec(query, nil); err != driver.ErrSkip {
+		t.Fatalf("expected the fast path to be skipped, but got: %v", err)
+	}
+	// database/sql would fall back to prepare the query right away
+	cn.Query("SELECT 1", nil)
+
+	if _, err = cn.Prepare(query); err != nil {
+		t.Fatalf("error '%s' was not expected, while preparing a statement", err)
+	}
+	if !prep.triggered {
+		t.Error("expected a later prepare to match the prepare expectation")
+	}
+}
+
+func TestAssertCallCountAtMost(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	records := 250
+	batch := 100
+	mock.ExpectExec("INSERT INTO events").Times(3).WillReturnResult(NewResult(0, int64(batch)))
+	mock.ExpectQuery("SELECT COUNT").WillReturnRows(NewRows([]string{"count"}).AddRow(records))
+
+	for i := 0; i < records; i += batch {
+		if _, err = db.Exec("INSERT INTO events SELECT * FROM staged_events LIMIT 100"); err != nil {
+			t.Fatalf("error '%s' was not expected, while inserting a batch", err)
+		}
+	}
+	var count int
+	if err = db.QueryRow("SELECT COUNT(*) FROM events").Scan(&count); err != nil {
+		t.Fatalf("error '%s' was not expected, while counting events", err)
+	}
+
+	if n := mock.CallCount(CallExec); n != 3 {
+		t.Errorf("expected 3 exec calls, but got %d", n)
+	}
+	if err := mock.AssertCallCountAtMost(CallExec, records/batch+1); err != nil {
+		t.Errorf("expected inserts to be batched, but: %s", err)
+	}
+	expected := "expected at most 2 Exec calls, but got 3"
+	if err := mock.AssertCallCountAtMost(CallExec, 2); err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s', but got '%v'", expected, err)
+	}
+	if err := mock.AssertCallCountAtMost(CallQuery, 1); err != nil {
+		t.Errorf("expected a single query, but: %s", err)
+	}
+}
+
+func TestWillReturnStaleRows(t *testing.T) {
+	t.Parallel()
+	primary, pmock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer primary.Close()
+	replica, rmock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer replica.Close()
+
+	pmock.ExpectExec("UPDATE users SET name").WithArgs("jane", 1).WillReturnResult(NewResult(0, 1))
+	rmock.ExpectQuery("SELECT name FROM users").WithArgs(1).WillReturnStaleRows(2,
+		NewRows([]string{"name"}).AddRow("john"),
+		NewRows([]string{"name"}).AddRow("jane"))
+
+	if _, err = primary.Exec("UPDATE users SET name = ? WHERE id = ?", "jane", 1); err != nil {
+		t.Fatalf("error '%s' was not expected, while updating a user", err)
+	}
+	var names []string
+	for i := 0; i < 3; i++ {
+		var name string
+		if err = replica.QueryRow("SELECT name FROM users WHERE id = ?", 1).Scan(&name); err != nil {
+			t.Fatalf("error '%s' was not expected, while reading a user", err)
+		}
+		names = append(names, name)
+	}
+	if !reflect.DeepEqual(names, []string{"john", "john", "jane"}) {
+		t.Errorf("expected the write to be visible on the third read, but got %v", names)
+	}
+
+	if err := pmock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled primary expectations: %s", err)
+	}
+	if err := rmock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled replica expectations: %s", err)
+	}
+
+	rmock.ExpectQuery("SELECT name FROM users").
+		WillReturnRows(NewRows([]string{"name"}).AddRow("joe")).
+		WillReturnStaleRows(1, NewRows([]string{"name"}).AddRow("john"), NewRows([]string{"name"}).AddRow("jane"))
+	rmock.ExpectQuery("SELECT name FROM users").
+		WillReturnStaleRows(1, NewRows([]string{"name"}).AddRow("john"), NewRows([]string{"name"}).AddRow("jane")).
+		WillReturnRows(NewRows([]string{"name"}).AddRow("joe"))
+	names = nil
+	for i := 0; i < 4; i++ {
+		var name string
+		if err = replica.QueryRow("SELECT name FROM users WHERE id = ?", 1).Scan(&name); err != nil {
+			t.Fatalf("error '%s' was not expected, while reading a user", err)
+		}
+		names = append(names, name)
+	}
+	if !reflect.DeepEqual(names, []string{"john", "jane", "joe", "joe"}) {
+		t.Errorf("expected the rows set last to be returned, but got %v", names)
+	}
+	if err := rmock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled replica expectations: %s", err)
+	}
+}
+
+func TestAutoIncrementCounter(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO users").WillReturnAutoIncrementId()
+	mock.ExpectExec("INSERT INTO users").WillReturnAutoIncrementId().WillReturnError(fmt.Errorf("duplicate entry"))
+	mock.ExpectExec("INSERT INTO users").WillReturnAutoIncrementId().WillReturnResult(NewResult(0, 3))
+	mock.ExpectExec("INSERT INTO users").WillReturnAutoIncrementId()
+
+	insert := func() (int64, error) {
+		res, err := db.Exec("INSERT INTO users(name) VALUES (?)", "john")
+		if err != nil {
+			return 0, err
+		}
+		return res.LastInsertId()
+	}
+
+	if id, err := insert(); err != nil || id != 1 {
+		t.Errorf("expected the first insert to get id 1, but got %d, %v", id, err)
+	}
+	if _, err := insert(); err == nil {
+		t.Error("expected the second insert to fail")
+	}
+	if n := mock.AutoIncrementCounter(); n != 1 {
+		t.Errorf("expected the failed insert not to advance the counter, but it is %d", n)
+	}
+	if id, err := insert(); err != nil || id != 2 {
+		t.Errorf("expected the bulk insert to get first id 2, but got %d, %v", id, err)
+	}
+	if id, err := insert(); err != nil || id != 5 {
+		t.Errorf("expected the last insert to get id 5, but got %d, %v", id, err)
+	}
+	if n := mock.AutoIncrementCounter(); n != 5 {
+		t.Errorf("expected the counter to be 5, but it is %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestExpectTx(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+
+	mock.ExpectTx([]TxStep{
+		Exec("UPDATE accounts SET balance = balance - ?").WithArgs(10, 1).WillReturnResult(NewResult(0, 1)),
+		Query("SELECT balance FROM accounts").WithArgs(1).WillReturnRows(NewRows([]string{"balance"}).AddRow(90)),
+	})
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while beginning a transaction", err)
+	}
+	var balance int
+	err = tx.QueryRow("SELECT balance FROM accounts WHERE id = ?", 1).Scan(&balance)
+	if err == nil {
+		t.Error("expected the query to be rejected before the update")
+	}
+	if _, err = tx.Exec("UPDATE accounts SET balance = balance - ? WHERE id = ?", 10, 1); err != nil {
+		t.Fatalf("error '%s' was not expected, while updating an account", err)
+	}
+	if err = tx.QueryRow("SELECT balance FROM accounts WHERE id = ?", 1).Scan(&balance); err != nil || balance != 90 {
+		t.Errorf("expected the balance to be 90, but got %d, %v", balance, err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("error '%s' was not expected, while committing a transaction", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestExpectTxConcurrentTransactions(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+
+	mock.ExpectTx([]TxStep{Exec("UPDATE orders").WillReturnResult(NewResult(0, 1))})
+	mock.ExpectTx([]TxStep{Exec("UPDATE invoices").WillReturnResult(NewResult(0, 1))})
+
+	orders, err := db.Begin()
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while beginning a transaction", err)
+	}
+	invoices, err := db.Begin()
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while beginning a transaction", err)
+	}
+
+	if _, err = invoices.Exec("UPDATE orders SET paid = true"); err == nil {
+		t.Error("expected an error, since orders are to be updated within the transaction begun first")
+	}
+	if _, err = orders.Exec("UPDATE orders SET paid = true"); err != nil {
+		t.Fatalf("error '%s' was not expected, while updating orders", err)
+	}
+	if _, err = invoices.Exec("UPDATE invoices SET paid = true"); err != nil {
+		t.Fatalf("error '%s' was not expected, while updating invoices", err)
+	}
+	if err = invoices.Commit(); err != nil {
+		t.Fatalf("error '%s' was not expected, while committing invoices", err)
+	}
+	if err = orders.Commit(); err != nil {
+		t.Fatalf("error '%s' was not expected, while committing orders", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestExpectTxCommitsAfterSteps(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+
+	mock.ExpectBegin()
+	mock.ExpectTx([]TxStep{
+		Exec("UPDATE accounts").WillReturnResult(NewResult(0, 1)),
+		Query("SELECT balance FROM accounts"),
+	})
+
+	early, err := db.Begin()
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while beginning a transaction", err)
+	}
+	if err = early.Commit(); err == nil {
+		t.Error("expected a commit before the steps ran to be rejected")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while beginning a transaction", err)
+	}
+	if _, err = tx.Exec("UPDATE accounts SET balance = 0"); err != nil {
+		t.Fatalf("error '%s' was not expected, while updating accounts", err)
+	}
+	var balance int
+	if err = tx.QueryRow("SELECT balance FROM accounts").Scan(&balance); err != sql.ErrNoRows {
+		t.Errorf("expected a query step without rows to return no rows, but got: %v", err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("error '%s' was not expected, while committing a transaction", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -2,27 +2,178 @@ package sqlmock
 
 import (
 	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"time"
 )
 
 type statement struct {
-	conn  *sqlmock
-	ex    *ExpectedPrepare
-	query string
+	conn       *conn
+	ex         *ExpectedPrepare
+	query      string
+	closes     int // guarded by the expectation lock
+	executions int
+}
+
+// StmtStats describes the lifecycle of a prepared statement,
+// across all connections it was prepared on.
+type StmtStats struct {
+	Query string
+
+	// Prepares is the number of times the statement was prepared
+	// at the driver level, including RePrepares.
+	Prepares int
+
+	// RePrepares is the number of times the statement was prepared
+	// again on a new connection, after its connection was lost.
+	RePrepares int
+
+	// Executions is the number of times the statement was
+	// executed or queried on a prepared statement.
+	Executions int
+
+	// Reuses is the number of executions made on a prepared
+	// statement, which was already executed before.
+	Reuses int
+
+	// Closes is the number of times a prepared statement
+	// was closed, including repeated closes.
+	Closes int
+}
+
+// stmtStats updates lifecycle statistics of the statement
+func (c *conn) stmtStats(query string, update func(*StmtStats)) {
+	query = stripQuery(query)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stmts == nil {
+		c.stmts = make(map[string]*StmtStats)
+	}
+	s, ok := c.stmts[query]
+	if !ok {
+		s = &StmtStats{Query: query}
+		c.stmts[query] = s
+	}
+	update(s)
+}
+
+// StatementStats returns lifecycle statistics
+// of the statement prepared with the given SQL
+func (c *sqlmock) StatementStats(stmtSQL string) StmtStats {
+	query := stripQuery(stmtSQL)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.stmts[query]; ok {
+		return *s
+	}
+	return StmtStats{Query: query}
+}
+
+// executed updates statistics of the statement,
+// once it was executed or queried
+func (stmt *statement) executed() {
+	stmt.conn.txStatement(stmt.query, func(s *TxStatementStats) { s.Executions++ })
+
+	reused := stmt.executions > 0
+	stmt.executions++
+	stmt.conn.stmtStats(stmt.query, func(s *StmtStats) {
+		s.Executions++
+		if reused {
+			s.Reuses++
+		}
+	})
 }
 
 func (stmt *statement) Close() error {
+	stmt.conn.stmtStats(stmt.query, func(s *StmtStats) { s.Closes++ })
+
+	stmt.ex.Lock()
+	defer stmt.ex.Unlock()
+
 	stmt.ex.wasClosed = true
+	stmt.closes++
+	if stmt.closes == 1 {
+		stmt.conn.drv.Lock()
+		stmt.conn.openStmts--
+		stmt.conn.drv.Unlock()
+	}
 	return stmt.ex.closeErr
 }
 
+// constantArgsMatch checks that arguments expected to be
+// constant were bound to the values they were bound first
+func (stmt *statement) constantArgsMatch(args []namedValue) error {
+	if len(stmt.ex.constant) == 0 {
+		return nil
+	}
+	stmt.conn.mu.Lock()
+	defer stmt.conn.mu.Unlock()
+	if stmt.ex.constants == nil {
+		stmt.ex.constants = make(map[int]driver.Value)
+	}
+	for _, arg := range args {
+		if !stmt.ex.constant[arg.Ordinal] {
+			continue
+		}
+		first, ok := stmt.ex.constants[arg.Ordinal]
+		if !ok {
+			stmt.ex.constants[arg.Ordinal] = arg.Value
+			continue
+		}
+		if !reflect.DeepEqual(first, arg.Value) {
+			return fmt.Errorf("argument %d was expected to stay [%T - %+v] across prepared statement reuse, but got [%T - %+v]", arg.Ordinal, first, first, arg.Value, arg.Value)
+		}
+	}
+	return nil
+}
+
 func (stmt *statement) NumInput() int {
-	return -1
+	if !stmt.ex.checksInput {
+		return -1
+	}
+	stmt.ex.Lock()
+	stmt.ex.inputChecks++
+	stmt.ex.Unlock()
+	return stmt.ex.numInput
+}
+
+// inputPassed counts a call made on the statement, which
+// passed the arity check of database/sql
+func (stmt *statement) inputPassed() {
+	if stmt == nil || !stmt.ex.checksInput {
+		return
+	}
+	stmt.ex.Lock()
+	stmt.ex.inputsPassed++
+	stmt.ex.Unlock()
 }
 
 func (stmt *statement) Exec(args []driver.Value) (driver.Result, error) {
-	return stmt.conn.Exec(stmt.query, args)
+	ex, res, err := stmt.conn.exec(stmt, stmt.query, ordinalValues(args))
+	if ex != nil {
+		start := time.Now()
+		time.Sleep(stmt.conn.statementDelay(ex.delay))
+		ex.delayed(start)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
 }
 
 func (stmt *statement) Query(args []driver.Value) (driver.Rows, error) {
-	return stmt.conn.Query(stmt.query, args)
+	ex, rows, err := stmt.conn.query(stmt, stmt.query, ordinalValues(args))
+	if ex != nil {
+		start := time.Now()
+		time.Sleep(stmt.conn.statementDelay(ex.delay))
+		ex.delayed(start)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return rows, nil
 }
@@ -4,6 +4,7 @@ package sqlmock
 
 import (
 	"errors"
+	"strings"
 	"testing"
 )
 
@@ -31,3 +32,229 @@ func TestExpectedPreparedStatementCloseError(t *testing.T) {
 		t.Fatalf("got = %v, want = %v", err, want)
 	}
 }
+
+func TestExpectedPreparedStatementClosedMoreThanOnce(t *testing.T) {
+	db, mock, err := NewWithDSN("sqlmock_db_stmt_double_close")
+	if err != nil {
+		t.Fatal("failed to open sqlmock database:", err)
+	}
+	defer db.Close()
+
+	ex := mock.ExpectPrepare("SELECT")
+
+	conn, err := db.Driver().Open("sqlmock_db_stmt_double_close")
+	if err != nil {
+		t.Fatal("unexpected error while opening driver connection:", err)
+	}
+
+	stmt, err := conn.Prepare("SELECT")
+	if err != nil {
+		t.Fatal("unexpected error while preparing a statement:", err)
+	}
+
+	if err := stmt.Close(); err != nil {
+		t.Fatal("unexpected error while closing a statement:", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("statement closed once should meet expectations, but got: %s", err)
+	}
+
+	if err := stmt.Close(); err != nil {
+		t.Fatal("unexpected error while closing a statement:", err)
+	}
+
+	if n := ex.CloseCount(); n != 2 {
+		t.Errorf("expected close count to be 2, but got: %d", n)
+	}
+
+	expected := "expected prepared statement to be closed exactly once, but the statement prepared on connection 2 was closed 2 times"
+	if err := mock.ExpectationsWereMet(); err == nil || !strings.HasPrefix(err.Error(), expected) {
+		t.Errorf("expected an error, since statement was closed twice, but got: %v", err)
+	}
+}
+
+func TestExpectedPreparedStatementCloseCount(t *testing.T) {
+	db, mock, err := New()
+	if err != nil {
+		t.Fatal("failed to open sqlmock database:", err)
+	}
+	defer db.Close()
+
+	ex := mock.ExpectPrepare("SELECT").WillBeClosed()
+
+	stmt, err := db.Prepare("SELECT")
+	if err != nil {
+		t.Fatal("unexpected error while preparing a statement:", err)
+	}
+
+	// database/sql closes the driver statement only once
+	stmt.Close()
+	stmt.Close()
+
+	if n := ex.CloseCount(); n != 1 {
+		t.Errorf("expected close count to be 1, but got: %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAssertStatementReuseInTx(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare("INSERT INTO users")
+	prep.ExpectExec().Times(3).WillReturnResult(NewResult(1, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while beginning a transaction", err)
+	}
+	stmt, err := tx.Prepare("INSERT INTO users(name) VALUES (?)")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while preparing a statement", err)
+	}
+	for _, name := range []string{"john", "jane", "joe"} {
+		if _, err = stmt.Exec(name); err != nil {
+			t.Fatalf("error '%s' was not expected, while inserting a row", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("error '%s' was not expected, while committing a transaction", err)
+	}
+
+	if err := mock.AssertStatementReuseInTx("INSERT INTO users", 2); err != nil {
+		t.Errorf("expected statement to be reused, but got: %s", err)
+	}
+	if err := mock.AssertStatementReuseInTx("INSERT INTO users", 3); err == nil {
+		t.Error("expected an error, since the statement was reused only twice")
+	}
+	if err := mock.AssertStatementReuseInTx("UPDATE users", 0); err == nil {
+		t.Error("expected an error, since no update statement was prepared")
+	}
+
+	stats := mock.TxStatementStats()
+	if len(stats) != 1 || stats[0].Tx != 1 || stats[0].Prepares != 1 || stats[0].Executions != 3 {
+		t.Errorf("unexpected transaction statement stats: %+v", stats)
+	}
+}
+
+func TestAssertStatementReuseInTxRePrepared(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	for i := 0; i < 2; i++ {
+		mock.ExpectPrepare("INSERT INTO users").ExpectExec().WillReturnResult(NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while beginning a transaction", err)
+	}
+	for _, name := range []string{"john", "jane"} {
+		stmt, err := tx.Prepare("INSERT INTO users(name) VALUES (?)")
+		if err != nil {
+			t.Fatalf("error '%s' was not expected, while preparing a statement", err)
+		}
+		if _, err = stmt.Exec(name); err != nil {
+			t.Fatalf("error '%s' was not expected, while inserting a row", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("error '%s' was not expected, while committing a transaction", err)
+	}
+
+	if err := mock.AssertStatementReuseInTx("INSERT INTO users", 0); err == nil {
+		t.Error("expected an error, since the statement was prepared twice in a transaction")
+	}
+}
+
+func TestExpectedPreparedStatementConstantArg(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatal("failed to open sqlmock database:", err)
+	}
+	defer db.Close()
+
+	prep := mock.ExpectPrepare("INSERT INTO items").WithConstantArg(1)
+	for i := 0; i < 3; i++ {
+		prep.ExpectExec().WillReturnResult(NewResult(int64(i+1), 1))
+	}
+
+	stmt, err := db.Prepare("INSERT INTO items(tenant_id, name) VALUES (?, ?)")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while preparing a statement", err)
+	}
+	defer stmt.Close()
+
+	for _, name := range []string{"one", "two"} {
+		if _, err = stmt.Exec(7, name); err != nil {
+			t.Fatalf("error '%s' was not expected, while inserting item %s", err, name)
+		}
+	}
+
+	_, err = stmt.Exec(8, "three")
+	expected := "ExecQuery 'INSERT INTO items(tenant_id, name) VALUES (?, ?)', argument 1 was expected to stay [int64 - 7] across prepared statement reuse, but got [int64 - 8]"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s', but got '%v'", expected, err)
+	}
+}
+
+func TestExpectedPreparedStatementNumInput(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatal("failed to open sqlmock database:", err)
+	}
+	defer db.Close()
+
+	prep := mock.ExpectPrepare("INSERT INTO users").WithNumInput(2)
+	prep.ExpectExec().WithArgs("john", 30).WillReturnResult(NewResult(1, 1))
+
+	stmt, err := db.Prepare("INSERT INTO users(name, age) VALUES (?, ?)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec("john")
+	if err == nil || err.Error() != "sql: expected 2 arguments, got 1" {
+		t.Errorf("expected the exec to be rejected by database/sql, but got: %v", err)
+	}
+	if n := prep.NumInputRejections(); n != 1 {
+		t.Errorf("expected 1 rejected call, but got %d", n)
+	}
+
+	if _, err = stmt.Exec("john", 30); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if n := prep.NumInputRejections(); n != 1 {
+		t.Errorf("expected the accepted call not to be counted as rejected, but got %d", n)
+	}
+
+	if _, err = stmt.Exec("john", struct{}{}); err == nil {
+		t.Error("expected the exec to be rejected, since an argument could not be converted")
+	}
+	if n := prep.NumInputRejections(); n != 2 {
+		t.Errorf("expected the conversion failure to be counted as rejected, but got %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,90 @@
+package sqlmock
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// rowStore holds rows inserted by execs expected with WillInsertInto,
+// keyed by table. Rows inserted within a transaction are visible
+// only to that transaction, until it is committed.
+type rowStore struct {
+	committed map[string][][]driver.Value
+	txs       map[int]map[string][][]driver.Value
+}
+
+// insert adds a row to the table, as seen by the connection
+func (c *conn) insert(table string, row []driver.Value) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.store.committed == nil {
+		c.store.committed = make(map[string][][]driver.Value)
+		c.store.txs = make(map[int]map[string][][]driver.Value)
+	}
+	tables := c.store.committed
+	if c.inTx {
+		if tables = c.store.txs[c.tx]; tables == nil {
+			tables = make(map[string][][]driver.Value)
+			c.store.txs[c.tx] = tables
+		}
+	}
+	tables[table] = append(tables[table], row)
+}
+
+// stored returns rows of the table, as seen by the connection
+func (c *conn) stored(table string, columns []string) (*Rows, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rows := c.store.committed[table]
+	if c.inTx {
+		rows = append(rows[:len(rows):len(rows)], c.store.txs[c.tx][table]...)
+	}
+
+	r := NewRows(columns)
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return nil, fmt.Errorf("row #%d stored in table '%s' has %d values, but %d columns were declared", i+1, table, len(row), len(columns))
+		}
+		r.rows = append(r.rows, row)
+	}
+	return r, nil
+}
+
+// endStore makes rows inserted within the transaction
+// visible to everyone if it was committed, or drops them
+func (c *conn) endStore(tx int, committed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if committed {
+		for table, rows := range c.store.txs[tx] {
+			c.store.committed[table] = append(c.store.committed[table], rows...)
+		}
+	}
+	delete(c.store.txs, tx)
+}
+
+// autoIncrement allocates ids for the rows affected by the
+// result from the auto-increment counter, unless it is an
+// error result
+func (c *sqlmock) autoIncrement(res driver.Result) driver.Result {
+	if r, ok := res.(*result); ok && r.err != nil {
+		return res
+	}
+	affected, err := res.RowsAffected()
+	if err != nil || affected <= 0 {
+		return res
+	}
+	c.mu.Lock()
+	id := c.lastAutoIncrID + 1
+	c.lastAutoIncrID += affected
+	c.mu.Unlock()
+	return &insertIDResult{Result: res, insertID: id}
+}
+
+// AutoIncrementCounter returns the last id allocated by
+// execs expected with WillReturnAutoIncrementId
+func (c *sqlmock) AutoIncrementCounter() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastAutoIncrID
+}
@@ -0,0 +1,70 @@
+package sqlmock
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+)
+
+func readNames(t *testing.T, query func(string, ...interface{}) (*sql.Rows, error)) string {
+	rows, err := query("SELECT id, name FROM users")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while querying users", err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			t.Fatalf("error '%s' was not expected, while scanning a user", err)
+		}
+		names = append(names, name)
+	}
+	return fmt.Sprint(names)
+}
+
+func TestStoredRowsReadYourWrites(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO users").WillInsertInto("users")
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO users").WillInsertInto("users")
+	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnStoredRows("users", "id", "name")
+	mock.ExpectRollback()
+	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnStoredRows("users", "id", "name")
+
+	if _, err = db.Exec("INSERT INTO users(id, name) VALUES (?, ?)", 1, "john"); err != nil {
+		t.Fatalf("error '%s' was not expected, while inserting a user", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while beginning a transaction", err)
+	}
+	res, err := tx.Exec("INSERT INTO users(id, name) VALUES (?, ?)", 2, "jane")
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while inserting a user", err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Errorf("expected one affected row, but got %d", n)
+	}
+	if actual := readNames(t, tx.Query); actual != "[john jane]" {
+		t.Errorf("expected the transaction to read its own writes, but got %s", actual)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatalf("error '%s' was not expected, while rolling back a transaction", err)
+	}
+
+	if actual := readNames(t, db.Query); actual != "[john]" {
+		t.Errorf("expected rows of the rolled back transaction to be dropped, but got %s", actual)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
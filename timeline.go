@@ -0,0 +1,98 @@
+package sqlmock
+
+import (
+	"fmt"
+	"sync"
+)
+
+// TxEventKind describes the kind of a transaction event
+// recorded by TxTimeline.
+type TxEventKind string
+
+// Kinds of recorded transaction events
+const (
+	TxBegin    TxEventKind = "Begin"
+	TxCommit   TxEventKind = "Commit"
+	TxRollback TxEventKind = "Rollback"
+)
+
+// TxEvent is a record of a transaction being begun, committed
+// or rolled back on one of the mocks sharing a TxTimeline.
+type TxEvent struct {
+	// Mock is the name the mock was given by TxTimelineOption.
+	Mock string
+	Kind TxEventKind
+
+	// Conn and Tx identify the connection and the
+	// transaction of the mock, like for Call.
+	Conn int
+	Tx   int
+
+	// Err is the error returned by Commit or Rollback,
+	// nil if the transaction was ended successfully.
+	Err error
+}
+
+// TxTimeline records transaction events of several mocks in the
+// order they happened, for example of two databases taking part in
+// a saga, so that tests can assert how their commits were sequenced.
+// Mocks record their events once configured by TxTimelineOption.
+type TxTimeline struct {
+	mu     sync.Mutex
+	events []TxEvent
+}
+
+// NewTxTimeline creates an empty transaction timeline,
+// to be shared by mocks with TxTimelineOption.
+func NewTxTimeline() *TxTimeline {
+	return &TxTimeline{}
+}
+
+// Events returns all transaction events recorded
+// so far, in the order they happened.
+func (t *TxTimeline) Events() []TxEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	events := make([]TxEvent, len(t.events))
+	copy(events, t.events)
+	return events
+}
+
+// AssertCommittedBefore checks that the mock named first committed
+// a transaction before the mock named second committed its first one.
+// Only commits which succeeded are considered, both mocks must have
+// committed.
+func (t *TxTimeline) AssertCommittedBefore(first, second string) error {
+	firstAt, secondAt := -1, -1
+	for i, ev := range t.Events() {
+		if ev.Kind != TxCommit || ev.Err != nil {
+			continue
+		}
+		if ev.Mock == first && firstAt < 0 {
+			firstAt = i
+		}
+		if ev.Mock == second && secondAt < 0 {
+			secondAt = i
+		}
+	}
+	switch {
+	case firstAt < 0:
+		return fmt.Errorf("expected '%s' to commit before '%s', but '%s' did not commit", first, second, first)
+	case secondAt < 0:
+		return fmt.Errorf("expected '%s' to commit before '%s', but '%s' did not commit", first, second, second)
+	case secondAt < firstAt:
+		return fmt.Errorf("expected '%s' to commit before '%s', but '%s' committed first", first, second, second)
+	}
+	return nil
+}
+
+// txEvent records a transaction event of the connection
+// to the timeline of the mock, if there is one
+func (c *conn) txEvent(kind TxEventKind, tx int, err error) {
+	if c.timeline == nil {
+		return
+	}
+	c.timeline.mu.Lock()
+	c.timeline.events = append(c.timeline.events, TxEvent{Mock: c.timelineName, Kind: kind, Conn: c.id, Tx: tx, Err: err})
+	c.timeline.mu.Unlock()
+}
@@ -0,0 +1,64 @@
+package sqlmock
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestTxTimelineCommitOrder(t *testing.T) {
+	t.Parallel()
+	timeline := NewTxTimeline()
+	open := func(name string) (*sql.DB, Sqlmock) {
+		db, mock, err := New(TxTimelineOption(timeline, name))
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO outbox").WillReturnResult(NewResult(1, 1))
+		mock.ExpectCommit()
+		return db, mock
+	}
+	orders, omock := open("orders")
+	defer orders.Close()
+	payments, pmock := open("payments")
+	defer payments.Close()
+
+	for _, db := range []*sql.DB{payments, orders} {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, err = tx.Exec("INSERT INTO outbox(event) VALUES ('created')"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err = tx.Commit(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	events := timeline.Events()
+	if len(events) != 4 || events[0].Mock != "payments" || events[0].Kind != TxBegin || events[3].Mock != "orders" || events[3].Kind != TxCommit {
+		t.Errorf("expected begin and commit events of both mocks, but got %+v", events)
+	}
+	if err := timeline.AssertCommittedBefore("payments", "orders"); err != nil {
+		t.Errorf("expected payments to commit first, but got: %s", err)
+	}
+	expected := "expected 'orders' to commit before 'payments', but 'payments' committed first"
+	if err := timeline.AssertCommittedBefore("orders", "payments"); err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s', but got: %v", expected, err)
+	}
+	expected = "expected 'ledger' to commit before 'orders', but 'ledger' did not commit"
+	if err := timeline.AssertCommittedBefore("ledger", "orders"); err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s', but got: %v", expected, err)
+	}
+	expected = "expected 'orders' to commit before 'ledger', but 'ledger' did not commit"
+	if err := timeline.AssertCommittedBefore("orders", "ledger"); err == nil || err.Error() != expected {
+		t.Errorf("expected error '%s', but got: %v", expected, err)
+	}
+
+	for _, mock := range []Sqlmock{omock, pmock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	}
+}
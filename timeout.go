@@ -0,0 +1,90 @@
+package sqlmock
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	setStatementTimeout   = regexp.MustCompile(`(?is)^\s*SET\s+(?:(SESSION|LOCAL)\s+)?statement_timeout\s*(?:=|\s+TO)\s*('[^']*'|\w+)\s*;?\s*$`)
+	resetStatementTimeout = regexp.MustCompile(`(?is)^\s*RESET\s+statement_timeout\s*;?\s*$`)
+	timeoutValue          = regexp.MustCompile(`(?i)^\s*(\d+)\s*(us|ms|s|min|h|d)?\s*$`)
+)
+
+// ErrStatementTimeout is returned by a query or exec, which is
+// delayed longer than the statement timeout of the connection,
+// set by a "SET statement_timeout" exec.
+var ErrStatementTimeout = errors.New("canceling statement due to statement timeout")
+
+var timeoutUnits = map[string]time.Duration{
+	"":    time.Millisecond,
+	"us":  time.Microsecond,
+	"ms":  time.Millisecond,
+	"s":   time.Second,
+	"min": time.Minute,
+	"h":   time.Hour,
+	"d":   24 * time.Hour,
+}
+
+// setTimeout tracks the statement timeout of the connection,
+// when query is a SET or RESET statement_timeout command. The
+// timeout set by SET LOCAL applies until the transaction ends.
+func (c *conn) setTimeout(query string) {
+	if resetStatementTimeout.MatchString(query) {
+		c.sessionTimeout, c.localTimeoutSet = 0, false
+		return
+	}
+	m := setStatementTimeout.FindStringSubmatch(query)
+	if m == nil {
+		return
+	}
+
+	var timeout time.Duration
+	if value := strings.Trim(m[2], "'"); !strings.EqualFold(value, "DEFAULT") {
+		v := timeoutValue.FindStringSubmatch(value)
+		if v == nil {
+			return // not a timeout the server would accept
+		}
+		n, _ := strconv.ParseInt(v[1], 10, 64)
+		timeout = time.Duration(n) * timeoutUnits[strings.ToLower(v[2])]
+	}
+
+	if strings.EqualFold(m[1], "LOCAL") {
+		if c.inTx {
+			c.localTimeout, c.localTimeoutSet = timeout, true
+		}
+		return
+	}
+	c.sessionTimeout, c.localTimeoutSet = timeout, false
+}
+
+// timeout returns the statement timeout in effect
+// for the connection, zero if it is disabled
+func (c *conn) timeout() time.Duration {
+	if c.inTx && c.localTimeoutSet {
+		return c.localTimeout
+	}
+	return c.sessionTimeout
+}
+
+// timedOut returns ErrStatementTimeout, if a statement
+// delayed for d would exceed the statement timeout
+func (c *conn) timedOut(d time.Duration) error {
+	if timeout := c.timeout(); timeout > 0 && c.delay(d) > timeout {
+		return ErrStatementTimeout
+	}
+	return nil
+}
+
+// statementDelay returns the duration to wait for a query or
+// exec delayed for d, which is cut short by the statement timeout
+func (c *conn) statementDelay(d time.Duration) time.Duration {
+	d = c.delay(d)
+	if timeout := c.timeout(); timeout > 0 && d > timeout {
+		return timeout
+	}
+	return d
+}
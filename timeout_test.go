@@ -0,0 +1,80 @@
+package sqlmock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatementTimeout(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	mock.ExpectExec("SET statement_timeout").WillReturnResult(NewResult(0, 0))
+	mock.ExpectQuery("SELECT").WillReturnRows(NewRows([]string{"id"}).AddRow(1)).WillDelayFor(200 * time.Millisecond)
+	mock.ExpectBegin()
+	mock.ExpectExec("SET LOCAL statement_timeout").WillReturnResult(NewResult(0, 0))
+	mock.ExpectQuery("SELECT").WillReturnRows(NewRows([]string{"id"}).AddRow(1)).WillDelayFor(100 * time.Millisecond)
+	mock.ExpectCommit()
+	mock.ExpectExec("RESET statement_timeout").WillReturnResult(NewResult(0, 0))
+	mock.ExpectQuery("SELECT").WillReturnRows(NewRows([]string{"id"}).AddRow(1)).WillDelayFor(100 * time.Millisecond)
+
+	if _, err = db.Exec("SET statement_timeout = 50"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	start := time.Now()
+	if _, err = db.Query("SELECT id FROM users"); err != ErrStatementTimeout {
+		t.Errorf("expected the query to time out, but got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 200*time.Millisecond {
+		t.Errorf("expected the query to be cancelled after the statement timeout, but it took %s", elapsed)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err = tx.Exec("SET LOCAL statement_timeout TO '1s'"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var id int
+	if err = tx.QueryRow("SELECT id FROM users").Scan(&id); err != nil {
+		t.Errorf("expected the query to complete within the local timeout, but got: %v", err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err = db.Exec("RESET statement_timeout"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err = db.QueryRow("SELECT id FROM users").Scan(&id); err != nil {
+		t.Errorf("expected the query not to time out after the timeout was reset, but got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStatementTimeoutValues(t *testing.T) {
+	t.Parallel()
+	c := &conn{}
+	for query, expected := range map[string]time.Duration{
+		"SET statement_timeout = 5000":           5 * time.Second,
+		"set statement_timeout to '250ms';":      250 * time.Millisecond,
+		"SET SESSION statement_timeout = '2min'": 2 * time.Minute,
+		"SET statement_timeout = 0":              0,
+		"SET statement_timeout TO DEFAULT":       0,
+	} {
+		c.sessionTimeout = time.Hour
+		c.setTimeout(query)
+		if c.sessionTimeout != expected {
+			t.Errorf("expected '%s' to set a timeout of %s, but got %s", query, expected, c.sessionTimeout)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package sqlmock
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var twoPhaseCommand = regexp.MustCompile(`(?is)^\s*(PREPARE\s+TRANSACTION|COMMIT\s+PREPARED|ROLLBACK\s+PREPARED)\s+'((?:[^']|'')*)'\s*;?\s*$`)
+
+// ExpectedTwoPhase is used to manage two-phase commit commands:
+// "PREPARE TRANSACTION 'gid'", "COMMIT PREPARED 'gid'" and
+// "ROLLBACK PREPARED 'gid'" executed by *sql.DB.Exec or *sql.Tx.Exec.
+// Returned by *Sqlmock.ExpectPrepareTransaction, *Sqlmock.ExpectCommitPrepared
+// and *Sqlmock.ExpectRollbackPrepared.
+type ExpectedTwoPhase struct {
+	commonExpectation
+	command string
+	gid     string
+}
+
+// WillReturnError allows to set an error for the command
+func (e *ExpectedTwoPhase) WillReturnError(err error) *ExpectedTwoPhase {
+	e.err = err
+	return e
+}
+
+// String returns string representation
+func (e *ExpectedTwoPhase) String() string {
+	msg := fmt.Sprintf("ExpectedTwoPhase => expecting %s '%s'", e.command, e.gid)
+	if e.err != nil {
+		msg += fmt.Sprintf(", which should return error: %s", e.err)
+	}
+	return msg
+}
+
+func (c *sqlmock) expectTwoPhase(command, gid string) *ExpectedTwoPhase {
+	e := &ExpectedTwoPhase{command: command, gid: gid}
+	c.expected = append(c.expected, e)
+	return e
+}
+
+func (c *sqlmock) ExpectPrepareTransaction(gid string) *ExpectedTwoPhase {
+	return c.expectTwoPhase("PREPARE TRANSACTION", gid)
+}
+
+func (c *sqlmock) ExpectCommitPrepared(gid string) *ExpectedTwoPhase {
+	return c.expectTwoPhase("COMMIT PREPARED", gid)
+}
+
+func (c *sqlmock) ExpectRollbackPrepared(gid string) *ExpectedTwoPhase {
+	return c.expectTwoPhase("ROLLBACK PREPARED", gid)
+}
+
+// twoPhaseExec handles a two-phase commit command, if the next
+// expectation is expecting it, and reports whether it was handled.
+// Otherwise the command is matched as any other exec.
+func (c *sqlmock) twoPhaseExec(query string) (bool, error) {
+	m := twoPhaseCommand.FindStringSubmatch(query)
+	if m == nil {
+		return false, nil
+	}
+	command := strings.ToUpper(strings.Join(strings.Fields(m[1]), " "))
+	gid := strings.Replace(m[2], "''", "'", -1)
+
+	var expected *ExpectedTwoPhase
+	for _, next := range c.expected {
+		next.Lock()
+		if next.fulfilled() {
+			next.Unlock()
+			continue
+		}
+
+		if tp, ok := next.(*ExpectedTwoPhase); ok && tp.command == command {
+			if tp.gid == gid {
+				expected = tp
+				break
+			}
+			if c.ordered {
+				next.Unlock()
+				return true, fmt.Errorf("ExecQuery '%s', %s '%s' was not expected, next expectation is: %s", query, command, gid, next)
+			}
+		}
+
+		next.Unlock()
+		if c.ordered {
+			return false, nil
+		}
+	}
+	if expected == nil {
+		return false, nil
+	}
+
+	expected.triggered = true
+	expected.Unlock()
+	if expected.err != nil {
+		return true, expected.err // mocked to return error
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if command == "PREPARE TRANSACTION" {
+		if c.globalTxs == nil {
+			c.globalTxs = make(map[string]bool)
+		}
+		c.globalTxs[gid] = true
+	} else {
+		delete(c.globalTxs, gid)
+	}
+	return true, nil
+}
+
+// globalTxsResolved checks whether all global transactions
+// prepared were either committed or rolled back
+func (c *sqlmock) globalTxsResolved() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var gids []string
+	for gid := range c.globalTxs {
+		gids = append(gids, gid)
+	}
+	if len(gids) == 0 {
+		return nil
+	}
+	sort.Strings(gids)
+	return fmt.Errorf("global transaction '%s' was prepared, but neither committed nor rolled back", gids[0])
+}
@@ -0,0 +1,69 @@
+package sqlmock
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTwoPhaseCommit(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE accounts").WillReturnResult(NewResult(0, 1))
+	mock.ExpectPrepareTransaction("tx-42")
+	mock.ExpectCommit()
+	mock.ExpectCommitPrepared("tx-42")
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("error '%s' was not expected, while beginning a transaction", err)
+	}
+	if _, err = tx.Exec("UPDATE accounts SET balance = balance - ?", 10); err != nil {
+		t.Fatalf("error '%s' was not expected, while updating accounts", err)
+	}
+	if _, err = tx.Exec("PREPARE TRANSACTION 'tx-42'"); err != nil {
+		t.Fatalf("error '%s' was not expected, while preparing a transaction", err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("error '%s' was not expected, while committing a transaction", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err == nil {
+		t.Error("expected an error, since the prepared transaction was not resolved")
+	}
+
+	if _, err = db.Exec("COMMIT PREPARED 'tx-41'"); err == nil {
+		t.Error("expected an error, since another global transaction was expected")
+	}
+	if _, err = db.Exec("commit prepared 'tx-42'"); err != nil {
+		t.Fatalf("error '%s' was not expected, while committing a prepared transaction", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTwoPhaseRollbackError(t *testing.T) {
+	t.Parallel()
+	db, mock, err := New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	errUnknown := errors.New(`prepared transaction with identifier "it's" does not exist`)
+	mock.ExpectRollbackPrepared("it's").WillReturnError(errUnknown)
+
+	if _, err = db.Exec("ROLLBACK PREPARED 'it''s'"); err != errUnknown {
+		t.Errorf("expected error '%s', but got '%v'", errUnknown, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,87 @@
+package sqlmock
+
+import "fmt"
+
+// TxStatementStats describes how a statement was prepared
+// and executed within a single transaction.
+type TxStatementStats struct {
+	Tx    int // number of the transaction, starting from 1
+	Query string
+
+	// Prepares is the number of times the statement
+	// was prepared within the transaction.
+	Prepares int
+
+	// Executions is the number of times the statement was
+	// executed or queried on a prepared statement.
+	Executions int
+}
+
+// txStatement updates statistics of the statement
+// within the current transaction, if any
+func (c *conn) txStatement(query string, update func(*TxStatementStats)) {
+	if !c.inTx {
+		return
+	}
+	query = stripQuery(query)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.txStats {
+		if s.Tx == c.tx && s.Query == query {
+			update(s)
+			return
+		}
+	}
+	s := &TxStatementStats{Tx: c.tx, Query: query}
+	update(s)
+	c.txStats = append(c.txStats, s)
+}
+
+// TxStatementStats returns statistics of statements
+// prepared within transactions
+func (c *sqlmock) TxStatementStats() []TxStatementStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := make([]TxStatementStats, len(c.txStats))
+	for i, s := range c.txStats {
+		stats[i] = *s
+	}
+	return stats
+}
+
+// AssertStatementReuseInTx checks that statements matching pattern
+// were prepared once and reused within their transactions
+func (c *sqlmock) AssertStatementReuseInTx(pattern string, minReuse int) error {
+	var found bool
+	for _, s := range c.TxStatementStats() {
+		if c.queryMatcher.Match(pattern, s.Query) != nil {
+			continue
+		}
+		found = true
+		if s.Prepares > 1 {
+			return fmt.Errorf("statement '%s' was prepared %d times in transaction %d, instead of being reused", s.Query, s.Prepares, s.Tx)
+		}
+		if reuse := s.Executions - s.Prepares; reuse < minReuse {
+			return fmt.Errorf("statement '%s' was reused %d times in transaction %d, but expected at least %d", s.Query, reuse, s.Tx, minReuse)
+		}
+	}
+	if !found {
+		return fmt.Errorf("no statement matching '%s' was prepared in a transaction", pattern)
+	}
+	return nil
+}
+
+// AssertAllInTransaction checks that every exec and query
+// matched so far was made within a transaction
+func (c *sqlmock) AssertAllInTransaction() error {
+	for _, call := range c.Calls() {
+		if call.Kind != CallExec && call.Kind != CallQuery {
+			continue
+		}
+		if !call.InTx {
+			return fmt.Errorf("expected all calls to be made within a transaction, but %s '%s' was made in auto-commit mode on connection %d", call.Kind, call.Query, call.Conn)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package sqlmock
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// TxStep is a statement expected to be executed within
+// a transaction declared by ExpectTx, built by Exec or Query.
+type TxStep struct {
+	query  string
+	exec   bool
+	args   []driver.Value
+	result driver.Result
+	rows   *Rows
+	err    error
+}
+
+// Exec builds a transaction step, which expects an exec
+// matching the given SQL, like ExpectExec does.
+func Exec(expectedSQL string) TxStep {
+	return TxStep{query: expectedSQL, exec: true}
+}
+
+// Query builds a transaction step, which expects a query
+// matching the given SQL, like ExpectQuery does.
+func Query(expectedSQL string) TxStep {
+	return TxStep{query: expectedSQL}
+}
+
+// WithArgs expects the step to be called with the
+// given arguments, like ExpectedExec.WithArgs does.
+func (s TxStep) WithArgs(args ...driver.Value) TxStep {
+	s.args = args
+	return s
+}
+
+// WillReturnResult sets the result of an exec step.
+func (s TxStep) WillReturnResult(result driver.Result) TxStep {
+	s.result = result
+	return s
+}
+
+// WillReturnRows sets the rows returned by a query step.
+func (s TxStep) WillReturnRows(rows *Rows) TxStep {
+	s.rows = rows
+	return s
+}
+
+// WillReturnError sets the error returned by the step.
+func (s TxStep) WillReturnError(err error) TxStep {
+	s.err = err
+	return s
+}
+
+// ExpectTx expects a transaction to be begun, to run the given
+// steps in order and to be committed. Steps are expected within
+// the transaction begun, in the given order, even if expectations
+// are not matched in order, and so is the commit, after the last
+// step. An exec step without a result returns a result with no rows
+// affected, while a query step without rows returns no rows. The
+// returned *ExpectedCommit allows to mock the Commit response.
+func (c *sqlmock) ExpectTx(steps []TxStep) *ExpectedCommit {
+	// the first step is bound to the transaction of the begin
+	var prev expectation = c.ExpectBegin()
+	for _, step := range steps {
+		constraints := []func(call *Call) error{inTransaction, after(prev), c.sameTxAs(prev)}
+		if step.exec {
+			e := c.ExpectExec(step.query)
+			e.args = step.args
+			if step.result == nil {
+				step.result = NewResult(0, 0)
+			}
+			e.WillReturnResult(step.result)
+			e.err = step.err
+			e.constraints = append(e.constraints, constraints...)
+			prev = e
+			continue
+		}
+
+		e := c.ExpectQuery(step.query)
+		e.args = step.args
+		if step.rows == nil {
+			step.rows = NewRows(nil)
+		}
+		e.WillReturnRows(step.rows)
+		e.err = step.err
+		e.constraints = append(e.constraints, constraints...)
+		prev = e
+	}
+
+	e := c.ExpectCommit()
+	e.lastStep = prev
+	return e
+}
+
+// stepsDone checks whether the last step of the transaction
+// declared by ExpectTx, if any, was fulfilled within the
+// transaction being committed. The commit expectation must be
+// locked by the caller.
+func (c *sqlmock) stepsDone(e *ExpectedCommit, tx int) error {
+	if e.lastStep == nil {
+		return nil
+	}
+	if err := after(e.lastStep)(nil); err != nil {
+		return err
+	}
+	return c.sameTxAs(e.lastStep)(&Call{Tx: tx})
+}
+
+// sameTxAs requires the call to be made within the same
+// transaction, as the one begun by the previous expectation
+// or the last call it matched, if any
+func (c *sqlmock) sameTxAs(prev expectation) func(call *Call) error {
+	return func(call *Call) error {
+		if prev == nil {
+			return nil
+		}
+		if begin, ok := prev.(*ExpectedBegin); ok {
+			begin.Lock()
+			tx := begin.tx
+			begin.Unlock()
+			if tx != call.Tx {
+				return fmt.Errorf("was expected to be called within transaction %d, but was called within transaction %d", tx, call.Tx)
+			}
+			return nil
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i := len(c.calls) - 1; i >= 0; i-- {
+			if c.calls[i].ex != prev {
+				continue
+			}
+			if tx := c.calls[i].Tx; tx != call.Tx {
+				return fmt.Errorf("was expected to be called within transaction %d, but was called within transaction %d", tx, call.Tx)
+			}
+			return nil
+		}
+		return nil
+	}
+}
+
+// after requires the previous expectation, if any,
+// to be fulfilled before the call is matched
+func after(prev expectation) func(call *Call) error {
+	return func(call *Call) error {
+		if prev == nil {
+			return nil
+		}
+		prev.Lock()
+		defer prev.Unlock()
+		if !prev.fulfilled() {
+			return fmt.Errorf("was called before the previous step of the transaction: %s", prev)
+		}
+		return nil
+	}
+}